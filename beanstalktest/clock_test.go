@@ -0,0 +1,27 @@
+package beanstalktest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	clock := beanstalktest.NewFakeClock(now)
+
+	require.Equal(t, now, clock.Now())
+
+	clock.Advance(1 * time.Minute)
+
+	require.Equal(t, now.Add(1*time.Minute), clock.Now())
+
+	clock.Advance(-1 * time.Minute)
+
+	require.Equal(t, now.Add(1*time.Minute), clock.Now())
+
+	require.False(t, beanstalktest.NewFakeClock(time.Time{}).Now().IsZero())
+}
@@ -0,0 +1,845 @@
+package beanstalktest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"gopkg.in/yaml.v2"
+)
+
+// is the longest command line accepted by beanstalkd, including the tube name
+const maxLineSize = 224
+
+const maxTubeNameSize = 200
+
+type conn struct {
+	server   *Server
+	rwc      net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	used     *tube
+	watched  []*tube
+	reserved map[int]*job
+	producer bool
+	worker   bool
+}
+
+func newConn(s *Server, rwc net.Conn) *conn {
+	c := &conn{
+		server:   s,
+		rwc:      rwc,
+		r:        bufio.NewReader(rwc),
+		w:        bufio.NewWriter(rwc),
+		reserved: map[int]*job{},
+	}
+
+	c.used = s.tube("default")
+	c.used.using++
+
+	c.watched = []*tube{c.used}
+	c.used.watching++
+
+	return c
+}
+
+func (c *conn) serve() {
+	defer c.server.wg.Done()
+	defer c.close()
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		if !strings.HasSuffix(line, "\r\n") || len(line) > maxLineSize {
+			if err = c.write(reply{line: "BAD_FORMAT"}); err != nil {
+				return
+			}
+
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			if err = c.write(reply{line: "UNKNOWN_COMMAND"}); err != nil {
+				return
+			}
+
+			continue
+		}
+
+		if fields[0] == "quit" {
+			return
+		}
+
+		c.server.count(fields[0])
+
+		var r reply
+
+		switch fields[0] {
+		case "put":
+			r, err = c.put(fields[1:])
+
+		case "reserve":
+			r = c.reserve(fields[1:], false)
+
+		case "reserve-with-timeout":
+			r = c.reserve(fields[1:], true)
+
+		default:
+			r = c.dispatch(fields[0], fields[1:])
+		}
+
+		if err != nil {
+			return
+		}
+
+		if err = c.write(r); err != nil {
+			return
+		}
+	}
+}
+
+// write sends a reply, buffering it while pipelined commands are pending.
+func (c *conn) write(r reply) error {
+	if _, err := c.w.WriteString(r.line + "\r\n"); err != nil {
+		return err
+	}
+
+	if r.body != nil {
+		if _, err := c.w.Write(r.body); err != nil {
+			return err
+		}
+
+		if _, err := c.w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+
+	if c.r.Buffered() > 0 {
+		return nil
+	}
+
+	return c.w.Flush()
+}
+
+// close releases the reservations of the connection and forgets its tubes.
+func (c *conn) close() {
+	_ = c.rwc.Close()
+
+	s := c.server
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.removeWaiter(c)
+
+	for _, j := range c.reserved {
+		j.state = readyState
+		j.reservedBy = nil
+	}
+
+	c.reserved = nil
+
+	c.used.using--
+	s.collect(c.used)
+
+	for _, t := range c.watched {
+		t.watching--
+		s.collect(t)
+	}
+
+	delete(s.conns, c)
+
+	s.process()
+}
+
+func (c *conn) watches(t *tube) bool {
+	for _, watched := range c.watched {
+		if watched == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *conn) deadlineSoon(now time.Time) bool {
+	for _, j := range c.reserved {
+		if !now.Before(j.deadlineAt.Add(-safetyMargin)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *conn) put(args []string) (reply, error) {
+	if len(args) != 4 {
+		return reply{line: "BAD_FORMAT"}, nil
+	}
+
+	priority, err1 := parseUint32(args[0])
+	delay, err2 := parseSeconds(args[1])
+	ttr, err3 := parseSeconds(args[2])
+	size, err4 := strconv.ParseUint(args[3], 10, 31)
+
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+		return reply{line: "BAD_FORMAT"}, nil
+	}
+
+	body := make([]byte, int(size)+2)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return reply{}, err
+	}
+
+	if int(size) > c.server.options.MaxJobSize {
+		return reply{line: "JOB_TOO_BIG"}, nil
+	}
+
+	if string(body[size:]) != "\r\n" {
+		return reply{line: "EXPECTED_CRLF"}, nil
+	}
+
+	if ttr < time.Second {
+		ttr = time.Second
+	}
+
+	s := c.server
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+
+	j := &job{
+		id:        s.nextID,
+		tube:      c.used,
+		priority:  priority,
+		delay:     delay,
+		ttr:       ttr,
+		body:      body[:size],
+		state:     readyState,
+		createdAt: now,
+	}
+
+	if delay > 0 {
+		j.state = delayedState
+		j.readyAt = now.Add(delay)
+	}
+
+	s.nextID++
+	s.jobs[j.id] = j
+	s.stats.TotalJobs++
+
+	c.used.jobs++
+	c.used.totalJobs++
+	c.producer = true
+
+	s.process()
+
+	return reply{line: "INSERTED " + itoa(j.id)}, nil
+}
+
+// reserve blocks until a job is available, the timeout elapses or a reserved job nears its deadline.
+func (c *conn) reserve(args []string, withTimeout bool) reply {
+	var timeout time.Duration
+
+	if withTimeout {
+		if len(args) != 1 {
+			return reply{line: "BAD_FORMAT"}
+		}
+
+		var err error
+		if timeout, err = parseSeconds(args[0]); err != nil {
+			return reply{line: "BAD_FORMAT"}
+		}
+	} else if len(args) != 0 {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	s.mutex.Lock()
+
+	s.process()
+
+	now := s.now()
+
+	c.worker = true
+
+	if j := s.nextReady(c, now); j != nil {
+		s.reserve(c, j, now)
+		s.mutex.Unlock()
+
+		return reserved(j)
+	}
+
+	if c.deadlineSoon(now) {
+		s.mutex.Unlock()
+
+		return reply{line: "DEADLINE_SOON"}
+	}
+
+	if withTimeout && timeout == 0 {
+		s.mutex.Unlock()
+
+		return reply{line: "TIMED_OUT"}
+	}
+
+	w := &waiter{conn: c, replyCh: make(chan reply, 1)}
+	if withTimeout {
+		w.deadline = now.Add(timeout)
+	}
+
+	s.waiters = append(s.waiters, w)
+	s.mutex.Unlock()
+
+	select {
+	case r := <-w.replyCh:
+		return r
+
+	case <-s.closeCh:
+		return reply{line: "TIMED_OUT"}
+	}
+}
+
+func (c *conn) dispatch(name string, args []string) reply {
+	s := c.server
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.process()
+
+	now := s.now()
+
+	var r reply
+
+	switch name {
+	case "use":
+		r = c.use(args)
+
+	case "watch":
+		r = c.watch(args)
+
+	case "ignore":
+		r = c.ignore(args)
+
+	case "reserve-job":
+		r = c.withJob(args, 0, func(j *job) reply {
+			if j.state == reservedState {
+				return reply{line: "NOT_FOUND"}
+			}
+
+			c.worker = true
+			s.reserve(c, j, now)
+
+			return reserved(j)
+		})
+
+	case "delete":
+		r = c.withJob(args, 0, func(j *job) reply {
+			if j.state == reservedState && j.reservedBy != c {
+				return reply{line: "NOT_FOUND"}
+			}
+
+			s.delete(j)
+
+			return reply{line: "DELETED"}
+		})
+
+	case "release":
+		r = c.withJob(args, 2, func(j *job) reply {
+			priority, err1 := parseUint32(args[1])
+			delay, err2 := parseSeconds(args[2])
+			if err1 != nil || err2 != nil {
+				return reply{line: "BAD_FORMAT"}
+			}
+
+			if j.reservedBy != c {
+				return reply{line: "NOT_FOUND"}
+			}
+
+			s.unreserve(j)
+
+			j.state = readyState
+			j.priority = priority
+			j.delay = delay
+			j.releases++
+
+			if delay > 0 {
+				j.state = delayedState
+				j.readyAt = now.Add(delay)
+			}
+
+			return reply{line: "RELEASED"}
+		})
+
+	case "bury":
+		r = c.withJob(args, 1, func(j *job) reply {
+			priority, err := parseUint32(args[1])
+			if err != nil {
+				return reply{line: "BAD_FORMAT"}
+			}
+
+			if j.reservedBy != c {
+				return reply{line: "NOT_FOUND"}
+			}
+
+			s.bury(j, priority)
+
+			return reply{line: "BURIED"}
+		})
+
+	case "touch":
+		r = c.withJob(args, 0, func(j *job) reply {
+			if j.reservedBy != c {
+				return reply{line: "NOT_FOUND"}
+			}
+
+			j.deadlineAt = now.Add(j.ttr)
+
+			return reply{line: "TOUCHED"}
+		})
+
+	case "peek":
+		r = c.withJob(args, 0, func(j *job) reply {
+			return found(j)
+		})
+
+	case "peek-ready":
+		r = c.peek(args, readyState)
+
+	case "peek-delayed":
+		r = c.peek(args, delayedState)
+
+	case "peek-buried":
+		r = c.peek(args, buriedState)
+
+	case "kick":
+		r = c.kick(args)
+
+	case "kick-job":
+		r = c.withJob(args, 0, func(j *job) reply {
+			if j.state != buriedState && j.state != delayedState {
+				return reply{line: "NOT_FOUND"}
+			}
+
+			s.kick(j)
+
+			return reply{line: "KICKED"}
+		})
+
+	case "stats-job":
+		r = c.withJob(args, 0, func(j *job) reply {
+			return yamlReply(c.statsJob(j, now))
+		})
+
+	case "stats-tube":
+		r = c.statsTube(args, now)
+
+	case "stats":
+		if len(args) != 0 {
+			return reply{line: "BAD_FORMAT"}
+		}
+
+		r = yamlReply(c.stats(now))
+
+	case "list-tubes":
+		if len(args) != 0 {
+			return reply{line: "BAD_FORMAT"}
+		}
+
+		names := make([]string, 0, len(s.tubes))
+		for name := range s.tubes {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		r = yamlReply(names)
+
+	case "list-tube-used":
+		if len(args) != 0 {
+			return reply{line: "BAD_FORMAT"}
+		}
+
+		r = reply{line: "USING " + c.used.name}
+
+	case "list-tubes-watched":
+		if len(args) != 0 {
+			return reply{line: "BAD_FORMAT"}
+		}
+
+		names := make([]string, 0, len(c.watched))
+		for _, t := range c.watched {
+			names = append(names, t.name)
+		}
+
+		r = yamlReply(names)
+
+	case "pause-tube":
+		r = c.pauseTube(args, now)
+
+	default:
+		return reply{line: "UNKNOWN_COMMAND"}
+	}
+
+	s.process()
+
+	return r
+}
+
+func (c *conn) use(args []string) reply {
+	if len(args) != 1 || !isValidTubeName(args[0]) {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	previous := c.used
+
+	c.used = s.tube(args[0])
+	c.used.using++
+
+	previous.using--
+	s.collect(previous)
+
+	return reply{line: "USING " + c.used.name}
+}
+
+func (c *conn) watch(args []string) reply {
+	if len(args) != 1 || !isValidTubeName(args[0]) {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	t := s.tube(args[0])
+	if !c.watches(t) {
+		c.watched = append(c.watched, t)
+		t.watching++
+	}
+
+	return reply{line: "WATCHING " + itoa(len(c.watched))}
+}
+
+func (c *conn) ignore(args []string) reply {
+	if len(args) != 1 || !isValidTubeName(args[0]) {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	for i, t := range c.watched {
+		if t.name != args[0] {
+			continue
+		}
+
+		if len(c.watched) == 1 {
+			return reply{line: "NOT_IGNORED"}
+		}
+
+		c.watched = append(c.watched[:i], c.watched[i+1:]...)
+
+		t.watching--
+		s.collect(t)
+
+		break
+	}
+
+	return reply{line: "WATCHING " + itoa(len(c.watched))}
+}
+
+// withJob parses the job id and the given number of extra arguments and passes the job to fn.
+func (c *conn) withJob(args []string, extra int, fn func(j *job) reply) reply {
+	if len(args) != extra+1 {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 63)
+	if err != nil {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	j, ok := c.server.jobs[int(id)]
+	if !ok {
+		return reply{line: "NOT_FOUND"}
+	}
+
+	return fn(j)
+}
+
+func (c *conn) peek(args []string, state string) reply {
+	if len(args) != 0 {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	j := c.server.peek(c.used, state)
+	if j == nil {
+		return reply{line: "NOT_FOUND"}
+	}
+
+	return found(j)
+}
+
+// kick moves up to bound buried jobs of the used tube to ready, or delayed jobs if none are buried.
+func (c *conn) kick(args []string) reply {
+	if len(args) != 1 {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	bound, err := strconv.ParseUint(args[0], 10, 31)
+	if err != nil {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	state := buriedState
+	if s.peek(c.used, buriedState) == nil {
+		state = delayedState
+	}
+
+	kicked := 0
+
+	for ; kicked < int(bound); kicked++ {
+		j := s.peek(c.used, state)
+		if j == nil {
+			break
+		}
+
+		s.kick(j)
+	}
+
+	return reply{line: "KICKED " + itoa(kicked)}
+}
+
+func (c *conn) pauseTube(args []string, now time.Time) reply {
+	if len(args) != 2 || !isValidTubeName(args[0]) {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	delay, err := parseSeconds(args[1])
+	if err != nil {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	t, ok := s.tubes[args[0]]
+	if !ok {
+		return reply{line: "NOT_FOUND"}
+	}
+
+	t.cmdPauseTube++
+	t.pause = delay
+	t.pausedUntil = now.Add(delay)
+
+	return reply{line: "PAUSED"}
+}
+
+func (c *conn) statsJob(j *job, now time.Time) beanstalk.StatsJob {
+	stats := beanstalk.StatsJob{
+		ID:       j.id,
+		Tube:     j.tube.name,
+		State:    j.state,
+		Priority: int(j.priority),
+		Age:      seconds(now.Sub(j.createdAt)),
+		Delay:    seconds(j.delay),
+		TTR:      seconds(j.ttr),
+		Reserves: j.reserves,
+		Timeouts: j.timeouts,
+		Releases: j.releases,
+		Buries:   j.buries,
+		Kicks:    j.kicks,
+	}
+
+	switch j.state {
+	case delayedState:
+		stats.TimeLeft = seconds(j.readyAt.Sub(now))
+
+	case reservedState:
+		stats.TimeLeft = seconds(j.deadlineAt.Sub(now))
+	}
+
+	return stats
+}
+
+func (c *conn) statsTube(args []string, now time.Time) reply {
+	if len(args) != 1 || !isValidTubeName(args[0]) {
+		return reply{line: "BAD_FORMAT"}
+	}
+
+	s := c.server
+
+	t, ok := s.tubes[args[0]]
+	if !ok {
+		return reply{line: "NOT_FOUND"}
+	}
+
+	stats := beanstalk.StatsTube{
+		Name:            t.name,
+		TotalJobs:       t.totalJobs,
+		CurrentUsing:    t.using,
+		CurrentWatching: t.watching,
+		Pause:           seconds(t.pause),
+		CmdDelete:       t.cmdDelete,
+		CmdPauseTube:    t.cmdPauseTube,
+	}
+
+	if t.paused(now) {
+		stats.PauseTimeLeft = seconds(t.pausedUntil.Sub(now))
+	}
+
+	for _, j := range s.jobs {
+		if j.tube != t {
+			continue
+		}
+
+		switch j.state {
+		case readyState:
+			stats.CurrentJobsReady++
+
+			if j.priority < urgentPriority {
+				stats.CurrentJobsUrgent++
+			}
+
+		case delayedState:
+			stats.CurrentJobsDelayed++
+
+		case reservedState:
+			stats.CurrentJobsReserved++
+
+		case buriedState:
+			stats.CurrentJobsBuried++
+		}
+	}
+
+	for _, w := range s.waiters {
+		if w.conn.watches(t) {
+			stats.CurrentWaiting++
+		}
+	}
+
+	return yamlReply(stats)
+}
+
+func (c *conn) stats(now time.Time) beanstalk.Stats {
+	s := c.server
+
+	stats := s.stats
+	stats.MaxJobSize = s.options.MaxJobSize
+	stats.CurrentTubes = len(s.tubes)
+	stats.CurrentConnections = len(s.conns)
+	stats.CurrentWaiting = len(s.waiters)
+	stats.PID = os.Getpid()
+	stats.Version = "beanstalktest"
+	stats.Uptime = seconds(now.Sub(s.started))
+	stats.ID = s.id
+	stats.Hostname = s.hostname
+	stats.OS = runtime.GOOS
+	stats.Platform = runtime.GOARCH
+
+	for other := range s.conns {
+		if other.producer {
+			stats.CurrentProducers++
+		}
+
+		if other.worker {
+			stats.CurrentWorkers++
+		}
+	}
+
+	for _, j := range s.jobs {
+		switch j.state {
+		case readyState:
+			stats.CurrentJobsReady++
+
+			if j.priority < urgentPriority {
+				stats.CurrentJobsUrgent++
+			}
+
+		case delayedState:
+			stats.CurrentJobsDelayed++
+
+		case reservedState:
+			stats.CurrentJobsReserved++
+
+		case buriedState:
+			stats.CurrentJobsBuried++
+		}
+	}
+
+	return stats
+}
+
+func found(j *job) reply {
+	return reply{line: "FOUND " + itoa(j.id) + " " + itoa(len(j.body)), body: j.body}
+}
+
+func yamlReply(v interface{}) reply {
+	body, err := yaml.Marshal(v)
+	if err != nil {
+		return reply{line: "INTERNAL_ERROR"}
+	}
+
+	body = append([]byte("---\n"), body...)
+
+	return reply{line: "OK " + itoa(len(body)), body: body}
+}
+
+func isValidTubeName(name string) bool {
+	if name == "" || len(name) > maxTubeNameSize || name[0] == '-' {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("-+/;.$_()", r):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseUint32(s string) (uint32, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+
+	return uint32(v), err
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(v) * time.Second, nil
+}
+
+// seconds truncates like beanstalkd and never reports a negative duration.
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+
+	return int(d / time.Second)
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
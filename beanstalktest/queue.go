@@ -0,0 +1,257 @@
+package beanstalktest
+
+import (
+	"time"
+)
+
+const (
+	readyState    = "ready"
+	delayedState  = "delayed"
+	reservedState = "reserved"
+	buriedState   = "buried"
+)
+
+// is the window before a TTR deadline in which reserve answers DEADLINE_SOON
+const safetyMargin = 1 * time.Second
+
+const urgentPriority = 1024
+
+type job struct {
+	id         int
+	tube       *tube
+	priority   uint32
+	delay      time.Duration
+	ttr        time.Duration
+	body       []byte
+	state      string
+	createdAt  time.Time
+	readyAt    time.Time
+	deadlineAt time.Time
+	reservedBy *conn
+	// orders buried jobs, which are kicked first in first out
+	buriedSeq uint64
+	reserves  int
+	timeouts  int
+	releases  int
+	buries    int
+	kicks     int
+}
+
+type tube struct {
+	name         string
+	jobs         int
+	using        int
+	watching     int
+	totalJobs    int
+	cmdDelete    int
+	cmdPauseTube int
+	pause        time.Duration
+	pausedUntil  time.Time
+}
+
+func (t *tube) paused(now time.Time) bool {
+	return now.Before(t.pausedUntil)
+}
+
+type reply struct {
+	line string
+	body []byte
+}
+
+type waiter struct {
+	conn *conn
+	// is zero for reserve without timeout
+	deadline time.Time
+	replyCh  chan reply
+}
+
+// tube returns the tube with the given name, creating it on first use.
+func (s *Server) tube(name string) *tube {
+	t, ok := s.tubes[name]
+	if !ok {
+		t = &tube{name: name}
+		s.tubes[name] = t
+	}
+
+	return t
+}
+
+// collect drops tubes that hold no jobs and are neither used nor watched.
+func (s *Server) collect(t *tube) {
+	if t.name == "default" || t.jobs > 0 || t.using > 0 || t.watching > 0 {
+		return
+	}
+
+	delete(s.tubes, t.name)
+}
+
+// process applies the transitions that became due and serves waiting reservations.
+func (s *Server) process() {
+	now := s.now()
+
+	for _, j := range s.jobs {
+		switch {
+		case j.state == delayedState && !now.Before(j.readyAt):
+			j.state = readyState
+
+		case j.state == reservedState && !now.Before(j.deadlineAt):
+			delete(j.reservedBy.reserved, j.id)
+
+			j.state = readyState
+			j.reservedBy = nil
+			j.timeouts++
+
+			s.stats.JobTimeouts++
+		}
+	}
+
+	for _, t := range s.tubes {
+		if t.pause > 0 && !t.paused(now) {
+			t.pause = 0
+			t.pausedUntil = time.Time{}
+		}
+	}
+
+	waiters := s.waiters[:0]
+
+	for _, w := range s.waiters {
+		if j := s.nextReady(w.conn, now); j != nil {
+			s.reserve(w.conn, j, now)
+
+			w.replyCh <- reserved(j)
+
+			continue
+		}
+
+		if w.conn.deadlineSoon(now) {
+			w.replyCh <- reply{line: "DEADLINE_SOON"}
+
+			continue
+		}
+
+		if !w.deadline.IsZero() && !now.Before(w.deadline) {
+			w.replyCh <- reply{line: "TIMED_OUT"}
+
+			continue
+		}
+
+		waiters = append(waiters, w)
+	}
+
+	for i := len(waiters); i < len(s.waiters); i++ {
+		s.waiters[i] = nil
+	}
+
+	s.waiters = waiters
+}
+
+// nextReady returns the most urgent ready job of the unpaused tubes watched by the connection.
+func (s *Server) nextReady(c *conn, now time.Time) *job {
+	var next *job
+
+	for _, j := range s.jobs {
+		if j.state != readyState || !c.watches(j.tube) || j.tube.paused(now) {
+			continue
+		}
+
+		if next == nil || j.priority < next.priority || (j.priority == next.priority && j.id < next.id) {
+			next = j
+		}
+	}
+
+	return next
+}
+
+// peek returns the first job of the tube in the given state, in the order the server would serve it.
+func (s *Server) peek(t *tube, state string) *job {
+	var next *job
+
+	for _, j := range s.jobs {
+		if j.tube != t || j.state != state {
+			continue
+		}
+
+		if next == nil || s.before(j, next) {
+			next = j
+		}
+	}
+
+	return next
+}
+
+func (s *Server) before(a, b *job) bool {
+	switch a.state {
+	case delayedState:
+		if !a.readyAt.Equal(b.readyAt) {
+			return a.readyAt.Before(b.readyAt)
+		}
+
+	case buriedState:
+		return a.buriedSeq < b.buriedSeq
+
+	default:
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+	}
+
+	return a.id < b.id
+}
+
+func (s *Server) reserve(c *conn, j *job, now time.Time) {
+	j.state = reservedState
+	j.reservedBy = c
+	j.deadlineAt = now.Add(j.ttr)
+	j.reserves++
+
+	c.reserved[j.id] = j
+}
+
+func (s *Server) bury(j *job, priority uint32) {
+	s.unreserve(j)
+
+	s.seq++
+
+	j.state = buriedState
+	j.priority = priority
+	j.buriedSeq = s.seq
+	j.buries++
+}
+
+func (s *Server) kick(j *job) {
+	j.state = readyState
+	j.kicks++
+}
+
+func (s *Server) unreserve(j *job) {
+	if j.reservedBy != nil {
+		delete(j.reservedBy.reserved, j.id)
+
+		j.reservedBy = nil
+	}
+}
+
+func (s *Server) delete(j *job) {
+	s.unreserve(j)
+
+	delete(s.jobs, j.id)
+
+	j.tube.jobs--
+	j.tube.cmdDelete++
+
+	s.collect(j.tube)
+}
+
+func (s *Server) removeWaiter(c *conn) {
+	for i, w := range s.waiters {
+		if w.conn == c {
+			s.waiters = append(s.waiters[:i], s.waiters[i+1:]...)
+
+			return
+		}
+	}
+}
+
+func reserved(j *job) reply {
+	return reply{line: "RESERVED " + itoa(j.id) + " " + itoa(len(j.body)), body: j.body}
+}
@@ -0,0 +1,227 @@
+// Package beanstalktest provides an in-process beanstalkd-compatible server for tests.
+package beanstalktest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+)
+
+var ErrClosedServer = errors.New("beanstalktest: server: closed")
+
+type ServerOptions struct {
+	// is the loopback address to listen on, "127.0.0.1:0" by default
+	Addr string
+	// is the maximum number of bytes in a job, 65535 by default
+	MaxJobSize int
+	// drives delays, TTR expiry, reservation timeouts and pauses, the system clock by default
+	Clock Clock
+}
+
+// Server implements the beanstalkd protocol in memory: tubes, priorities, delays,
+// TTR expiry, bury/kick, pause-tube and stats. Time-based transitions are
+// evaluated on every command and on a short tick, against ServerOptions.Clock.
+type Server struct {
+	// is the address of the listener, e.g. "127.0.0.1:11300"
+	Addr string
+
+	options  *ServerOptions
+	listener net.Listener
+	id       string
+	hostname string
+	started  time.Time
+	nextID   int
+	seq      uint64
+	jobs     map[int]*job
+	tubes    map[string]*tube
+	conns    map[*conn]struct{}
+	waiters  []*waiter
+	stats    beanstalk.Stats
+	closed   bool
+	closeCh  chan struct{}
+	wg       sync.WaitGroup
+	mutex    sync.Mutex
+}
+
+// NewServer starts a server on a loopback port, it panics if listening fails.
+func NewServer() *Server {
+	return NewServerWithOptions(&ServerOptions{})
+}
+
+func NewServerWithOptions(options *ServerOptions) *Server {
+	if options.Addr == "" {
+		options.Addr = "127.0.0.1:0"
+	}
+
+	if options.MaxJobSize <= 0 {
+		options.MaxJobSize = 65535
+	}
+
+	if options.Clock == nil {
+		options.Clock = realClock{}
+	}
+
+	listener, err := net.Listen("tcp", options.Addr)
+	if err != nil {
+		panic("beanstalktest: failed to listen on " + options.Addr + ": " + err.Error())
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+
+	hostname, _ := os.Hostname()
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		options:  options,
+		listener: listener,
+		id:       hex.EncodeToString(id),
+		hostname: hostname,
+		started:  options.Clock.Now(),
+		nextID:   1,
+		jobs:     map[int]*job{},
+		tubes:    map[string]*tube{},
+		conns:    map[*conn]struct{}{},
+		closeCh:  make(chan struct{}),
+	}
+
+	s.tube("default")
+
+	s.wg.Add(2)
+
+	go s.accept()
+	go s.tick()
+
+	return s
+}
+
+// Dialer returns a factory suitable for PoolOptions.Dialer and WorkerOptions.Dialer.
+func (s *Server) Dialer() func() (*beanstalk.Client, error) {
+	return beanstalk.NewDialer(s.Addr, &beanstalk.DialOptions{Timeout: 5 * time.Second})
+}
+
+// Close stops the listener, closes every connection and waits for them to finish.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+
+	if s.closed {
+		s.mutex.Unlock()
+
+		return ErrClosedServer
+	}
+
+	s.closed = true
+
+	close(s.closeCh)
+
+	err := s.listener.Close()
+
+	for c := range s.conns {
+		_ = c.rwc.Close()
+	}
+
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+
+	for {
+		rwc, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mutex.Lock()
+
+		if s.closed {
+			s.mutex.Unlock()
+
+			_ = rwc.Close()
+
+			return
+		}
+
+		c := newConn(s, rwc)
+
+		s.conns[c] = struct{}{}
+		s.stats.TotalConnections++
+
+		s.wg.Add(1)
+
+		s.mutex.Unlock()
+
+		go c.serve()
+	}
+}
+
+// tick wakes up waiting reservations whose jobs or timeouts became due, it polls
+// in real time, so advancing a FakeClock takes effect within a tick.
+func (s *Server) tick() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closeCh:
+			return
+
+		case <-ticker.C:
+			s.mutex.Lock()
+			s.process()
+			s.mutex.Unlock()
+		}
+	}
+}
+
+func (s *Server) now() time.Time {
+	return s.options.Clock.Now()
+}
+
+// count updates the cumulative command counters reported by stats.
+func (s *Server) count(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	counters := map[string]*int{
+		"put":                  &s.stats.CmdPut,
+		"peek":                 &s.stats.CmdPeek,
+		"peek-ready":           &s.stats.CmdPeekReady,
+		"peek-delayed":         &s.stats.CmdPeekDelayed,
+		"peek-buried":          &s.stats.CmdPeekBuried,
+		"reserve":              &s.stats.CmdReserve,
+		"reserve-with-timeout": &s.stats.CmdReserve,
+		"use":                  &s.stats.CmdUse,
+		"watch":                &s.stats.CmdWatch,
+		"ignore":               &s.stats.CmdIgnore,
+		"delete":               &s.stats.CmdDelete,
+		"release":              &s.stats.CmdRelease,
+		"bury":                 &s.stats.CmdBury,
+		"kick":                 &s.stats.CmdKick,
+		"kick-job":             &s.stats.CmdKick,
+		"touch":                &s.stats.CmdTouch,
+		"stats":                &s.stats.CmdStats,
+		"stats-job":            &s.stats.CmdStatsJob,
+		"stats-tube":           &s.stats.CmdStatsTube,
+		"list-tubes":           &s.stats.CmdListTubes,
+		"list-tube-used":       &s.stats.CmdListTubeUsed,
+		"list-tubes-watched":   &s.stats.CmdListTubesWatched,
+		"pause-tube":           &s.stats.CmdPauseTube,
+	}
+
+	if counter, ok := counters[name]; ok {
+		*counter++
+	}
+}
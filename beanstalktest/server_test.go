@@ -0,0 +1,506 @@
+package beanstalktest_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func dial(t *testing.T, s *beanstalktest.Server) *beanstalk.Client {
+	t.Helper()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	return c
+}
+
+func TestServer(t *testing.T) {
+	t.Run("put / reserve / delete", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		c := dial(t, s)
+
+		tube, err := c.Use("test")
+
+		require.Nil(t, err)
+		require.Equal(t, "test", tube)
+
+		id, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+		require.Equal(t, 1, id)
+
+		_, err = c.Watch("test")
+
+		require.Nil(t, err)
+
+		job, err := c.ReserveWithTimeout(0)
+
+		require.Nil(t, err)
+		require.Equal(t, &beanstalk.Job{ID: 1, Data: []byte("test")}, job)
+
+		stats, err := c.StatsJob(1)
+
+		require.Nil(t, err)
+		require.Equal(t, "test", stats.Tube)
+		require.Equal(t, "reserved", stats.State)
+		require.Equal(t, 1, stats.Reserves)
+		require.Equal(t, 60, stats.TTR)
+
+		require.Nil(t, c.Delete(1))
+		require.Equal(t, beanstalk.ErrNotFound, c.Delete(1))
+
+		_, err = c.ReserveWithTimeout(0)
+
+		require.Equal(t, beanstalk.ErrTimedOut, err)
+	})
+
+	t.Run("priorities", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		c := dial(t, s)
+
+		for _, priority := range []uint32{10, 1, 5, 1} {
+			_, err := c.Put(priority, 0, 1*time.Minute, []byte("test"))
+
+			require.Nil(t, err)
+		}
+
+		var ids []int
+
+		for i := 0; i < 4; i++ {
+			job, err := c.ReserveWithTimeout(0)
+
+			require.Nil(t, err)
+
+			ids = append(ids, job.ID)
+		}
+
+		require.Equal(t, []int{2, 4, 3, 1}, ids)
+	})
+
+	t.Run("release / bury / kick", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		c := dial(t, s)
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("a"))
+
+		require.Nil(t, err)
+
+		_, err = c.Put(1, 0, 1*time.Minute, []byte("b"))
+
+		require.Nil(t, err)
+
+		job, err := c.Reserve()
+
+		require.Nil(t, err)
+		require.Nil(t, c.Release(job.ID, 1, 0))
+
+		job, err = c.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, 1, job.ID)
+		require.Nil(t, c.Bury(job.ID, 1))
+
+		job, err = c.Reserve()
+
+		require.Nil(t, err)
+		require.Nil(t, c.Bury(job.ID, 1))
+
+		job, err = c.PeekBuried()
+
+		require.Nil(t, err)
+		require.Equal(t, 1, job.ID)
+
+		kicked, err := c.Kick(1)
+
+		require.Nil(t, err)
+		require.Equal(t, 1, kicked)
+
+		require.Nil(t, c.KickJob(2))
+		require.Equal(t, beanstalk.ErrNotFound, c.KickJob(2))
+
+		stats, err := c.StatsTube("default")
+
+		require.Nil(t, err)
+		require.Equal(t, 2, stats.CurrentJobsReady)
+		require.Equal(t, 0, stats.CurrentJobsBuried)
+
+		jobStats, err := c.StatsJob(1)
+
+		require.Nil(t, err)
+		require.Equal(t, 1, jobStats.Releases)
+		require.Equal(t, 1, jobStats.Buries)
+		require.Equal(t, 1, jobStats.Kicks)
+	})
+
+	t.Run("reservations of other connections", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		producer := dial(t, s)
+		consumer := dial(t, s)
+
+		id, err := producer.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+
+		_, err = consumer.Reserve()
+
+		require.Nil(t, err)
+
+		require.Equal(t, beanstalk.ErrNotFound, producer.Delete(id))
+		require.Equal(t, beanstalk.ErrNotFound, producer.Touch(id))
+
+		// reservations of a closed connection are returned to the ready queue
+		require.NoError(t, consumer.Close())
+
+		require.Eventually(t, func() bool {
+			stats, err := producer.StatsJob(id)
+
+			return err == nil && stats.State == "ready"
+		}, 1*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("waiting reservation", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		producer := dial(t, s)
+		consumer := dial(t, s)
+
+		_, err := consumer.Watch("test")
+
+		require.Nil(t, err)
+
+		jobCh := make(chan *beanstalk.Job, 1)
+
+		go func() {
+			job, _ := consumer.ReserveWithTimeout(5 * time.Second)
+
+			jobCh <- job
+		}()
+
+		require.Eventually(t, func() bool {
+			stats, err := producer.Stats()
+
+			return err == nil && stats.CurrentWaiting == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		_, err = producer.Use("test")
+
+		require.Nil(t, err)
+
+		id, err := producer.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+
+		select {
+		case job := <-jobCh:
+			require.Equal(t, id, job.ID)
+
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "reservation was not served")
+		}
+	})
+
+	t.Run("tubes", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		c := dial(t, s)
+
+		_, err := c.Watch("b")
+
+		require.Nil(t, err)
+
+		count, err := c.Watch("a")
+
+		require.Nil(t, err)
+		require.Equal(t, 3, count)
+
+		_, err = c.Ignore("default")
+
+		require.Nil(t, err)
+
+		_, err = c.Ignore("b")
+
+		require.Nil(t, err)
+
+		_, err = c.Ignore("a")
+
+		require.Equal(t, beanstalk.ErrNotIgnored, err)
+
+		tubes, err := c.ListTubes()
+
+		require.Nil(t, err)
+		require.Equal(t, []string{"a", "default"}, tubes)
+
+		tubes, err = c.ListTubesWatched()
+
+		require.Nil(t, err)
+		require.Equal(t, []string{"a"}, tubes)
+
+		require.Nil(t, c.PauseTube("a", 1*time.Minute))
+		require.Equal(t, beanstalk.ErrNotFound, c.PauseTube("missing", 1*time.Minute))
+
+		stats, err := c.StatsTube("a")
+
+		require.Nil(t, err)
+		require.Equal(t, 60, stats.Pause)
+		require.Equal(t, 1, stats.CmdPauseTube)
+
+		_, err = c.Use("-invalid")
+
+		require.Equal(t, beanstalk.ErrBadFormat, err)
+	})
+
+	t.Run("job too big", func(t *testing.T) {
+		s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{MaxJobSize: 4})
+
+		defer s.Close()
+
+		c := dial(t, s)
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("tests"))
+
+		require.Equal(t, beanstalk.ErrJobTooBig, err)
+
+		// the connection stays usable after the body was discarded
+		_, err = c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		c := dial(t, s)
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+
+		_, err = c.Put(2048, 1*time.Minute, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+
+		stats, err := c.Stats()
+
+		require.Nil(t, err)
+		require.Equal(t, 2, stats.CmdPut)
+		require.Equal(t, 2, stats.TotalJobs)
+		require.Equal(t, 1, stats.CurrentJobsReady)
+		require.Equal(t, 1, stats.CurrentJobsUrgent)
+		require.Equal(t, 1, stats.CurrentJobsDelayed)
+		require.Equal(t, 1, stats.CurrentConnections)
+		require.Equal(t, 1, stats.CurrentProducers)
+		require.Equal(t, 65535, stats.MaxJobSize)
+
+		job, err := c.PeekDelayed()
+
+		require.Nil(t, err)
+		require.Equal(t, 2, job.ID)
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{Dialer: s.Dialer(), Capacity: 2})
+
+		require.NoError(t, pool.Open(context.Background()))
+
+		c, err := pool.Get()
+
+		require.NoError(t, err)
+
+		_, err = c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+		require.NoError(t, pool.Put(c))
+
+		stats, err := c.Stats()
+
+		require.Nil(t, err)
+		require.Equal(t, 2, stats.CurrentConnections)
+
+		require.NoError(t, pool.Close(context.Background()))
+	})
+
+	t.Run("close", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		c := dial(t, s)
+
+		require.NoError(t, s.Close())
+		require.Equal(t, beanstalktest.ErrClosedServer, s.Close())
+
+		_, err := c.Stats()
+
+		require.Error(t, err)
+	})
+}
+
+func TestServer_Clock(t *testing.T) {
+	newServer := func(t *testing.T) (*beanstalktest.Server, *beanstalktest.FakeClock, *beanstalk.Client) {
+		clock := beanstalktest.NewFakeClock(time.Time{})
+
+		s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: clock})
+
+		t.Cleanup(func() {
+			_ = s.Close()
+		})
+
+		return s, clock, dial(t, s)
+	}
+
+	t.Run("delay", func(t *testing.T) {
+		_, clock, c := newServer(t)
+
+		id, err := c.Put(1, 10*time.Second, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+
+		stats, err := c.StatsJob(id)
+
+		require.Nil(t, err)
+		require.Equal(t, "delayed", stats.State)
+		require.Equal(t, 10, stats.TimeLeft)
+
+		clock.Advance(9 * time.Second)
+
+		_, err = c.ReserveWithTimeout(0)
+
+		require.Equal(t, beanstalk.ErrTimedOut, err)
+
+		clock.Advance(1 * time.Second)
+
+		job, err := c.ReserveWithTimeout(0)
+
+		require.Nil(t, err)
+		require.Equal(t, id, job.ID)
+	})
+
+	t.Run("ttr", func(t *testing.T) {
+		_, clock, c := newServer(t)
+
+		id, err := c.Put(1, 0, 3*time.Second, []byte("test"))
+
+		require.Nil(t, err)
+
+		_, err = c.Reserve()
+
+		require.Nil(t, err)
+
+		clock.Advance(1 * time.Second)
+
+		stats, err := c.StatsJob(id)
+
+		require.Nil(t, err)
+		require.Equal(t, "reserved", stats.State)
+		require.Equal(t, 2, stats.TimeLeft)
+
+		clock.Advance(1 * time.Second)
+
+		// the reserved job is within the one second safety margin
+		_, err = c.ReserveWithTimeout(10 * time.Second)
+
+		require.Equal(t, beanstalk.ErrDeadlineSoon, err)
+
+		require.Nil(t, c.Touch(id))
+
+		clock.Advance(3 * time.Second)
+
+		stats, err = c.StatsJob(id)
+
+		require.Nil(t, err)
+		require.Equal(t, "ready", stats.State)
+		require.Equal(t, 1, stats.Timeouts)
+
+		require.Nil(t, c.Delete(id))
+	})
+
+	t.Run("pause", func(t *testing.T) {
+		_, clock, c := newServer(t)
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+		require.Nil(t, c.PauseTube("default", 30*time.Second))
+
+		_, err = c.ReserveWithTimeout(0)
+
+		require.Equal(t, beanstalk.ErrTimedOut, err)
+
+		stats, err := c.StatsTube("default")
+
+		require.Nil(t, err)
+		require.Equal(t, 30, stats.PauseTimeLeft)
+
+		clock.Advance(30 * time.Second)
+
+		_, err = c.ReserveWithTimeout(0)
+
+		require.Nil(t, err)
+
+		stats, err = c.StatsTube("default")
+
+		require.Nil(t, err)
+		require.Equal(t, 0, stats.Pause)
+	})
+
+	t.Run("waiting reservation", func(t *testing.T) {
+		s, clock, c := newServer(t)
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			_, err := c.ReserveWithTimeout(1 * time.Minute)
+
+			errCh <- err
+		}()
+
+		other := dial(t, s)
+
+		// the reservation must be waiting before the clock moves
+		require.Eventually(t, func() bool {
+			stats, err := other.Stats()
+
+			return err == nil && stats.CurrentWaiting == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		clock.Advance(1 * time.Minute)
+
+		select {
+		case err := <-errCh:
+			require.Equal(t, beanstalk.ErrTimedOut, err)
+
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "reservation did not time out")
+		}
+	})
+}
@@ -0,0 +1,291 @@
+package beanstalk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// is set on reference jobs whose body is kept in a BlobStore
+const HeaderBlobKey = "Blob-Key"
+
+var ErrBlobNotFound = errors.New("beanstalk: blob: not found")
+
+// BlobError is returned when the body of a reserved reference job cannot be fetched. The job
+// stays reserved, so it can be released or buried by its id.
+type BlobError struct {
+	ID  int
+	Key string
+	Err error
+}
+
+func (e *BlobError) Error() string {
+	return fmt.Sprintf("beanstalk: blob: failed to fetch payload of job %d: %s", e.ID, e.Err)
+}
+
+func (e *BlobError) Unwrap() error {
+	return e.Err
+}
+
+// BlobStore keeps job bodies that are too big for the server, see ClientOptions.BlobStore.
+type BlobStore interface {
+	Put(ctx context.Context, data []byte) (string, error)
+	// returns ErrBlobNotFound for unknown keys
+	Get(ctx context.Context, key string) ([]byte, error)
+	// succeeds for unknown keys
+	Delete(ctx context.Context, key string) error
+}
+
+// FileBlobStore keeps one file per blob in a directory, e.g. on a volume shared by producers and consumers.
+type FileBlobStore struct {
+	dir string
+}
+
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileBlobStore{dir: dir}, nil
+}
+
+func (s *FileBlobStore) Put(_ context.Context, data []byte) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	key := hex.EncodeToString(b)
+
+	// writes to a temporary file first, so readers never see a partial blob
+	f, err := os.CreateTemp(s.dir, ".tmp-"+key)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = f.Write(data); err == nil {
+		err = f.Close()
+	} else {
+		_ = f.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(s.dir, key))
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+
+		return "", err
+	}
+
+	return key, nil
+}
+
+func (s *FileBlobStore) Get(_ context.Context, key string) ([]byte, error) {
+	if !isBlobKey(key) {
+		return nil, ErrBlobNotFound
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrBlobNotFound
+	}
+
+	return data, err
+}
+
+func (s *FileBlobStore) Delete(_ context.Context, key string) error {
+	if !isBlobKey(key) {
+		return nil
+	}
+
+	err := os.Remove(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// isBlobKey rejects keys that would escape the directory.
+func isBlobKey(key string) bool {
+	_, err := hex.DecodeString(key)
+
+	return key != "" && err == nil
+}
+
+// offload moves the payload of a put into the blob store and returns a reference job
+// with the headers of the payload and the key of the blob, as well as the key.
+func (c *Client) offload(ctx context.Context, command Command, force bool) (Command, string, error) {
+	put, ok := command.(PutCommand)
+	if !ok || c.options.BlobStore == nil {
+		return command, "", nil
+	}
+
+	if !force && (c.options.BlobThreshold <= 0 || len(put.Data) <= c.options.BlobThreshold) {
+		return command, "", nil
+	}
+
+	headers, body, err := DecodeEnvelope(put.Data)
+	if err != nil || headers.Get(HeaderBlobKey) != "" {
+		return command, "", nil
+	}
+
+	key, err := c.options.BlobStore.Put(ctx, body)
+	if err != nil {
+		return nil, "", fmt.Errorf("beanstalk: blob: failed to store payload: %w", err)
+	}
+
+	reference := make(Headers, len(headers)+1)
+	for k, v := range headers {
+		reference[k] = v
+	}
+
+	reference[HeaderBlobKey] = key
+
+	if put.Data, err = EncodeEnvelope(reference, nil); err != nil {
+		return nil, "", err
+	}
+
+	c.options.Logger.Log(DebugLogLevel, "Offloaded job payload", map[string]interface{}{"key": key, "size": len(body)})
+
+	return put, key, nil
+}
+
+// discardBlob removes the blob of a reference job that was not put.
+func (c *Client) discardBlob(key string) {
+	if key == "" {
+		return
+	}
+
+	if err := c.options.BlobStore.Delete(context.Background(), key); err != nil {
+		c.options.Logger.Log(WarningLogLevel, "Failed to delete blob", map[string]interface{}{"key": key, "error": err})
+	}
+}
+
+// claim fetches the bodies of reference jobs and deletes blobs of jobs deleted after their
+// reservation. Blobs of released and buried jobs are kept for the next reservation.
+func (c *Client) claim(ctx context.Context, command Command, response CommandResponse) (CommandResponse, error) {
+	if c.options.BlobStore == nil {
+		return response, nil
+	}
+
+	switch command := command.(type) {
+	case DeleteCommand:
+		if key := c.forgetBlob(command.ID); key != "" {
+			c.discardBlob(key)
+		}
+
+		return response, nil
+
+	case ReleaseCommand:
+		c.forgetBlob(command.ID)
+
+		return response, nil
+
+	case BuryCommand:
+		c.forgetBlob(command.ID)
+
+		return response, nil
+	}
+
+	var id int
+	var data []byte
+	var reserved bool
+
+	switch r := response.(type) {
+	case ReserveCommandResponse:
+		id, data, reserved = r.ID, r.Data, true
+	case ReserveWithTimeoutCommandResponse:
+		id, data, reserved = r.ID, r.Data, true
+	case ReserveJobCommandResponse:
+		id, data, reserved = r.ID, r.Data, true
+	case PeekCommandResponse:
+		id, data = r.ID, r.Data
+	case PeekReadyCommandResponse:
+		id, data = r.ID, r.Data
+	case PeekDelayedCommandResponse:
+		id, data = r.ID, r.Data
+	case PeekBuriedCommandResponse:
+		id, data = r.ID, r.Data
+	default:
+		return response, nil
+	}
+
+	headers, _, err := DecodeEnvelope(data)
+	if err != nil || headers.Get(HeaderBlobKey) == "" {
+		return response, nil
+	}
+
+	key := headers.Get(HeaderBlobKey)
+
+	body, err := c.options.BlobStore.Get(ctx, key)
+	if err != nil && reserved {
+		return nil, &BlobError{ID: id, Key: key, Err: err}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("beanstalk: blob: failed to fetch payload of job %d: %w", id, err)
+	}
+
+	// the key stays with the client only, so a payload put again is offloaded to a new blob
+	delete(headers, HeaderBlobKey)
+
+	data = body
+
+	if len(headers) > 0 {
+		if data, err = EncodeEnvelope(headers, body); err != nil {
+			return nil, err
+		}
+	}
+
+	// peeked jobs are not tracked, as they are deleted, released or buried by their holder
+	if reserved {
+		c.mutex.Lock()
+		c.blobs[id] = key
+		c.mutex.Unlock()
+	}
+
+	switch response.(type) {
+	case ReserveCommandResponse:
+		return ReserveCommandResponse{id, data}, nil
+	case ReserveWithTimeoutCommandResponse:
+		return ReserveWithTimeoutCommandResponse{id, data}, nil
+	case ReserveJobCommandResponse:
+		return ReserveJobCommandResponse{id, data}, nil
+	case PeekCommandResponse:
+		return PeekCommandResponse{id, data}, nil
+	case PeekReadyCommandResponse:
+		return PeekReadyCommandResponse{id, data}, nil
+	case PeekDelayedCommandResponse:
+		return PeekDelayedCommandResponse{id, data}, nil
+	default:
+		return PeekBuriedCommandResponse{id, data}, nil
+	}
+}
+
+func (c *Client) forgetBlob(id int) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key := c.blobs[id]
+	delete(c.blobs, id)
+
+	return key
+}
+
+// isRejected reports whether the server answered a put without storing the job.
+func isRejected(err error) bool {
+	switch err {
+	case ErrJobTooBig, ErrDraining, ErrOutOfMemory, ErrExpectedCRLF, ErrBadFormat, ErrUnknownCommand:
+		return true
+
+	default:
+		return false
+	}
+}
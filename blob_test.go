@@ -0,0 +1,298 @@
+package beanstalk_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBlobStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := beanstalk.NewFileBlobStore(dir)
+
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	key, err := store.Put(ctx, []byte("test"))
+
+	require.NoError(t, err)
+
+	data, err := store.Get(ctx, key)
+
+	require.NoError(t, err)
+	require.Equal(t, []byte("test"), data)
+	require.Len(t, blobs(t, dir), 1)
+
+	require.NoError(t, store.Delete(ctx, key))
+	require.NoError(t, store.Delete(ctx, key))
+	require.Empty(t, blobs(t, dir))
+
+	_, err = store.Get(ctx, key)
+
+	require.ErrorIs(t, err, beanstalk.ErrBlobNotFound)
+
+	// keys never escape the directory
+	_, err = store.Get(ctx, "../"+key)
+
+	require.ErrorIs(t, err, beanstalk.ErrBlobNotFound)
+}
+
+func TestClient_BlobStore(t *testing.T) {
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{MaxJobSize: 200})
+
+	defer s.Close()
+
+	dir := t.TempDir()
+
+	store, err := beanstalk.NewFileBlobStore(dir)
+
+	require.NoError(t, err)
+
+	dial := func(options *beanstalk.ClientOptions) *beanstalk.Client {
+		c, err := beanstalk.DialWithOptions(s.Addr, &beanstalk.DialOptions{Client: options})
+
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			_ = c.Close()
+		})
+
+		return c
+	}
+
+	consumer := dial(&beanstalk.ClientOptions{BlobStore: store})
+
+	large := []byte(strings.Repeat("test", 100))
+
+	t.Run("Rejected", func(t *testing.T) {
+		_, err := dial(&beanstalk.ClientOptions{}).Put(1, 0, 1*time.Minute, large)
+
+		require.ErrorIs(t, err, beanstalk.ErrJobTooBig)
+
+		producer := dial(&beanstalk.ClientOptions{BlobStore: store})
+
+		id, err := producer.Put(1, 0, 1*time.Minute, large)
+
+		require.Nil(t, err)
+		require.Len(t, blobs(t, dir), 1)
+
+		// the reference job fits the server
+		job, err := producer.Peek(id)
+
+		require.Nil(t, err)
+		require.Equal(t, large, job.Body())
+
+		job, err = consumer.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, id, job.ID)
+		require.Equal(t, large, job.Body())
+		require.Empty(t, job.Headers().Get(beanstalk.HeaderBlobKey))
+
+		// the blob is kept until the job is deleted
+		require.Nil(t, consumer.Release(job.ID, 1, 0))
+		require.Len(t, blobs(t, dir), 1)
+
+		job, err = consumer.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, large, job.Body())
+
+		require.Nil(t, consumer.Delete(job.ID))
+		require.Empty(t, blobs(t, dir))
+	})
+
+	t.Run("Threshold", func(t *testing.T) {
+		logger := &recordingLogger{}
+
+		producer := dial(&beanstalk.ClientOptions{Logger: logger, BlobStore: store, BlobThreshold: 50})
+
+		_, err := beanstalk.PutValue(producer, &beanstalk.PutOptions{
+			TTR:     1 * time.Minute,
+			Headers: beanstalk.Headers{beanstalk.HeaderTraceID: "abc"},
+		}, strings.Repeat("test", 20))
+
+		require.Nil(t, err)
+		require.Len(t, logger.entries, 1)
+		require.Equal(t, "Offloaded job payload", logger.entries[0].msg)
+
+		job, err := consumer.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, "abc", job.Headers().Get(beanstalk.HeaderTraceID))
+
+		var v string
+
+		require.NoError(t, job.Decode(&v))
+		require.Equal(t, strings.Repeat("test", 20), v)
+
+		require.Nil(t, consumer.Delete(job.ID))
+		require.Empty(t, blobs(t, dir))
+	})
+
+	t.Run("Compressed", func(t *testing.T) {
+		producer := dial(&beanstalk.ClientOptions{BlobStore: store, Compressor: beanstalk.GzipCompressor, CompressThreshold: 100})
+
+		// compresses to more than the server accepts
+		r := rand.New(rand.NewSource(1))
+
+		huge := make([]byte, 4096)
+		for i := range huge {
+			huge[i] = "acgt"[r.Intn(4)]
+		}
+
+		_, err := producer.Put(1, 0, 1*time.Minute, huge)
+
+		require.Nil(t, err)
+
+		job, err := consumer.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, "gzip", job.Headers().Get(beanstalk.HeaderContentEncoding))
+		require.Equal(t, huge, job.Body())
+
+		require.Nil(t, consumer.Delete(job.ID))
+		require.Empty(t, blobs(t, dir))
+	})
+
+	t.Run("Pipeline", func(t *testing.T) {
+		producer := dial(&beanstalk.ClientOptions{BlobStore: store, BlobThreshold: 50})
+
+		results, err := producer.Pipeline().
+			Add(beanstalk.PutCommand{Priority: 1, TTR: 1 * time.Minute, Data: large}).
+			Add(beanstalk.PutCommand{Priority: 1, TTR: 1 * time.Minute, Data: []byte("small")}).
+			Execute()
+
+		require.Nil(t, err)
+		require.Nil(t, results[0].Err)
+		require.Nil(t, results[1].Err)
+		require.Len(t, blobs(t, dir), 1)
+
+		job, err := consumer.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, large, job.Body())
+
+		require.Nil(t, consumer.Delete(job.ID))
+
+		job, err = consumer.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, []byte("small"), job.Data)
+		require.Nil(t, consumer.Delete(job.ID))
+		require.Empty(t, blobs(t, dir))
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		ctx := context.Background()
+
+		mover := dial(&beanstalk.ClientOptions{BlobStore: store, BlobThreshold: 100})
+
+		_, err := mover.Use("outbox")
+
+		require.NoError(t, err)
+
+		_, err = mover.Put(1, 0, 1*time.Minute, large)
+
+		require.NoError(t, err)
+
+		// the moved job gets a blob of its own and the blob of the original is deleted
+		moved, err := beanstalk.MoveJobs(ctx, mover, "outbox", "exported", nil)
+
+		require.NoError(t, err)
+		require.Len(t, moved, 1)
+		require.Len(t, blobs(t, dir), 1)
+
+		var b bytes.Buffer
+
+		count, err := beanstalk.ExportTube(ctx, mover, "exported", &b, &beanstalk.ExportOptions{Delete: true})
+
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		require.Empty(t, blobs(t, dir))
+
+		count, err = beanstalk.ImportJobs(ctx, mover, &b, &beanstalk.ImportOptions{Tube: "default"})
+
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+		require.Len(t, blobs(t, dir), 1)
+
+		job, err := consumer.Reserve()
+
+		require.NoError(t, err)
+		require.Equal(t, large, job.Body())
+
+		require.Nil(t, consumer.Delete(job.ID))
+		require.Empty(t, blobs(t, dir))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		producer := dial(&beanstalk.ClientOptions{BlobStore: store})
+
+		id, err := producer.Put(1, 0, 1*time.Minute, large)
+
+		require.Nil(t, err)
+
+		for _, entry := range blobs(t, dir) {
+			require.NoError(t, os.Remove(filepath.Join(dir, entry.Name())))
+		}
+
+		_, err = consumer.Reserve()
+
+		var blobErr *beanstalk.BlobError
+
+		require.ErrorAs(t, err, &blobErr)
+		require.ErrorIs(t, err, beanstalk.ErrBlobNotFound)
+		require.Equal(t, id, blobErr.ID)
+
+		// the job stays reserved, so it can be buried
+		stats, err := consumer.StatsJob(id)
+
+		require.Nil(t, err)
+		require.Equal(t, "reserved", stats.State)
+		require.Nil(t, consumer.Bury(id, 1))
+
+		// workers retry the job following their policy
+		require.Nil(t, consumer.KickJob(id))
+
+		w := beanstalk.NewWorker(beanstalk.HandlerFunc(func(ctx context.Context, job *beanstalk.Job) error {
+			return nil
+		}), &beanstalk.WorkerOptions{
+			Dialer:         beanstalk.NewDialer(s.Addr, &beanstalk.DialOptions{Client: &beanstalk.ClientOptions{BlobStore: store}}),
+			ReserveTimeout: 100 * time.Millisecond,
+			RetryPolicy:    beanstalk.ConstantRetryPolicy{MaxAttempts: 1},
+		})
+
+		require.NoError(t, w.Start())
+
+		require.Eventually(t, func() bool {
+			stats, err := consumer.StatsJob(id)
+
+			return err == nil && stats.State == "buried"
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, w.Shutdown(context.Background()))
+		require.Nil(t, consumer.Delete(id))
+	})
+}
+
+func blobs(t *testing.T, dir string) []os.DirEntry {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+
+	require.NoError(t, err)
+
+	return entries
+}
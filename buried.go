@@ -0,0 +1,133 @@
+package beanstalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// BuriedFilter selects buried jobs, all conditions must hold.
+type BuriedFilter struct {
+	// selects jobs by payload, all jobs by default
+	Data func(data []byte) bool
+	// selects jobs at least this old
+	MinAge time.Duration
+	// selects jobs buried at least this many times, counted as kicks plus one, as a buried job
+	// leaves the queue only when kicked, while inspections bury jobs again and grow Buries
+	MinBuries int
+	// limits the number of matching jobs, all by default, all jobs are inspected regardless
+	Limit int
+}
+
+func (f *BuriedFilter) match(job *Job, stats *StatsJob) bool {
+	if f == nil {
+		return true
+	}
+
+	if f.Data != nil && !f.Data(job.Data) {
+		return false
+	}
+
+	return time.Duration(stats.Age)*time.Second >= f.MinAge && stats.Kicks+1 >= f.MinBuries
+}
+
+type BuriedJob struct {
+	ID    int
+	Data  []byte
+	Stats *StatsJob
+}
+
+// BuriedReport lists the matching jobs an action was applied to.
+type BuriedReport struct {
+	// is the number of buried jobs inspected
+	Inspected int
+	Jobs      []BuriedJob
+}
+
+// BuriedManager inspects all buried jobs of a tube, not only the head shown by PeekBuried.
+// Each action peeks the buried jobs one by one and buries the ones it leaves buried again
+// with their priority, which moves them to the back of the queue, until the first one comes
+// around again. So their reserves and buries statistics grow, and a single job is reserved
+// at a time.
+type BuriedManager struct {
+	client *Client
+	tube   string
+}
+
+func NewBuriedManager(c *Client, tube string) *BuriedManager {
+	return &BuriedManager{
+		client: c,
+		tube:   tube,
+	}
+}
+
+// List reports the matching jobs and leaves them buried.
+func (m *BuriedManager) List(ctx context.Context, filter *BuriedFilter) (*BuriedReport, error) {
+	return m.scan(ctx, filter, func(s *scannedJob) (bool, error) {
+		return false, nil
+	})
+}
+
+// Kick kicks the matching jobs into the ready queue.
+func (m *BuriedManager) Kick(ctx context.Context, filter *BuriedFilter) (*BuriedReport, error) {
+	return m.scan(ctx, filter, func(s *scannedJob) (bool, error) {
+		if err := m.client.KickJobContext(ctx, s.job.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+// Delete deletes the matching jobs.
+func (m *BuriedManager) Delete(ctx context.Context, filter *BuriedFilter) (*BuriedReport, error) {
+	return m.scan(ctx, filter, func(s *scannedJob) (bool, error) {
+		if err := deleteJob(ctx, m.client, s.job.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+// Export writes the matching jobs to w as JSON Lines of ExportedJob, see ImportJobs, and
+// leaves them buried.
+func (m *BuriedManager) Export(ctx context.Context, filter *BuriedFilter, w io.Writer) (*BuriedReport, error) {
+	enc := json.NewEncoder(w)
+
+	return m.scan(ctx, filter, func(s *scannedJob) (bool, error) {
+		return false, enc.Encode(s.export())
+	})
+}
+
+// scan applies the action to the matching jobs, which reports whether it took the job out
+// of the buried queue. All buried jobs are inspected, even beyond the limit, so the ones
+// left buried keep their order.
+func (m *BuriedManager) scan(ctx context.Context, filter *BuriedFilter, action func(s *scannedJob) (bool, error)) (*BuriedReport, error) {
+	report := &BuriedReport{}
+
+	err := scanJobs(ctx, m.client, m.tube, []string{BuriedState}, func(s *scannedJob) (bool, error) {
+		report.Inspected++
+
+		if filter != nil && filter.Limit > 0 && len(report.Jobs) >= filter.Limit {
+			return false, nil
+		}
+
+		if !filter.match(s.job, s.stats) {
+			return false, nil
+		}
+
+		taken, err := action(s)
+		if err != nil {
+			return false, err
+		}
+
+		report.Jobs = append(report.Jobs, BuriedJob{ID: s.job.ID, Data: s.job.Data, Stats: s.stats})
+
+		return taken, nil
+	})
+
+	return report, err
+}
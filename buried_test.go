@@ -0,0 +1,216 @@
+package beanstalk_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuriedManager(t *testing.T) {
+	clock := beanstalktest.NewFakeClock(time.Now())
+
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: clock})
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	_, err = c.Use("emails")
+
+	require.NoError(t, err)
+
+	bury := func(data string) int {
+		id, err := c.Put(10, 0, 30*time.Second, []byte(data))
+
+		require.NoError(t, err)
+
+		_, err = c.ReserveJob(id)
+
+		require.NoError(t, err)
+		require.NoError(t, c.Bury(id, 20))
+
+		return id
+	}
+
+	// jobs 1 and 2 are old, job 2 was buried twice and job 3 is recent
+	bury("invoice 1")
+	bury("invoice 2")
+	require.NoError(t, c.KickJob(2))
+	_, err = c.ReserveJob(2)
+	require.NoError(t, err)
+	require.NoError(t, c.Bury(2, 20))
+
+	clock.Advance(1 * time.Hour)
+
+	bury("report 3")
+
+	_, err = c.Use("default")
+
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	m := beanstalk.NewBuriedManager(c, "emails")
+
+	ids := func(report *beanstalk.BuriedReport) []int {
+		ids := make([]int, 0, len(report.Jobs))
+		for _, job := range report.Jobs {
+			ids = append(ids, job.ID)
+		}
+
+		return ids
+	}
+
+	requireBuried := func(expected int) {
+		stats, err := c.StatsTube("emails")
+
+		require.NoError(t, err)
+		require.Equal(t, expected, stats.CurrentJobsBuried)
+		require.Equal(t, 0, stats.CurrentJobsReserved)
+	}
+
+	t.Run("List", func(t *testing.T) {
+		report, err := m.List(ctx, &beanstalk.BuriedFilter{MinBuries: 2})
+
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, ids(report))
+
+		report, err = m.List(ctx, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, 3, report.Inspected)
+		require.Equal(t, []int{1, 2, 3}, ids(report))
+		require.Equal(t, []byte("invoice 1"), report.Jobs[0].Data)
+		require.Equal(t, 20, report.Jobs[0].Stats.Priority)
+		requireBuried(3)
+
+		report, err = m.List(ctx, &beanstalk.BuriedFilter{MinAge: 30 * time.Minute})
+
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2}, ids(report))
+
+		report, err = m.List(ctx, &beanstalk.BuriedFilter{
+			Data: func(data []byte) bool {
+				return bytes.HasPrefix(data, []byte("invoice"))
+			},
+			Limit: 1,
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, 3, report.Inspected)
+		require.Equal(t, []int{1}, ids(report))
+		requireBuried(3)
+
+		job, err := c.StatsJob(1)
+
+		require.NoError(t, err)
+		require.Equal(t, beanstalk.BuriedState, job.State)
+		require.Equal(t, 20, job.Priority)
+
+		// the buries of the inspections are not counted
+		report, err = m.List(ctx, &beanstalk.BuriedFilter{MinBuries: 2})
+
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, ids(report))
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		var b bytes.Buffer
+
+		report, err := m.Export(ctx, &beanstalk.BuriedFilter{
+			Data: func(data []byte) bool {
+				return string(data) == "invoice 2"
+			},
+		}, &b)
+
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, ids(report))
+		require.JSONEq(t, `{"id":2,"tube":"emails","state":"buried","priority":20,"delay":0,"ttr":30,"data":"aW52b2ljZSAy"}`, b.String())
+		requireBuried(3)
+	})
+
+	t.Run("Kick", func(t *testing.T) {
+		report, err := m.Kick(ctx, &beanstalk.BuriedFilter{MinAge: 30 * time.Minute, Limit: 1})
+
+		require.NoError(t, err)
+		require.Equal(t, []int{1}, ids(report))
+		requireBuried(2)
+
+		job, err := c.StatsJob(1)
+
+		require.NoError(t, err)
+		require.Equal(t, beanstalk.ReadyState, job.State)
+		require.Equal(t, 20, job.Priority)
+		require.Equal(t, 1, job.Kicks)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		report, err := m.Delete(ctx, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Inspected)
+		require.Equal(t, []int{2, 3}, ids(report))
+
+		_, err = c.PeekBuried()
+
+		require.ErrorIs(t, err, beanstalk.ErrNotFound)
+
+		stats, err := c.StatsTube("emails")
+
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.CurrentJobsReady)
+		require.Equal(t, 0, stats.CurrentJobsBuried)
+	})
+}
+
+func TestBuriedManager_Expiry(t *testing.T) {
+	clock := beanstalktest.NewFakeClock(time.Now())
+
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: clock})
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	for _, data := range []string{"first", "second"} {
+		id, err := c.Put(10, 0, 1*time.Second, []byte(data))
+
+		require.NoError(t, err)
+
+		_, err = c.ReserveJob(id)
+
+		require.NoError(t, err)
+		require.NoError(t, c.Bury(id, 10))
+	}
+
+	// the TTR of the jobs runs out while each job is inspected
+	report, err := beanstalk.NewBuriedManager(c, "default").List(context.Background(), &beanstalk.BuriedFilter{
+		Data: func(data []byte) bool {
+			clock.Advance(2 * time.Second)
+
+			return true
+		},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, report.Jobs, 2)
+
+	stats, err := c.StatsTube("default")
+
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.CurrentJobsBuried)
+	require.Equal(t, 0, stats.CurrentJobsReady)
+}
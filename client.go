@@ -1,44 +1,98 @@
 package beanstalk
 
 import (
+	"context"
+	"errors"
 	"io"
-	"net"
 	"net/textproto"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/artiifact/go-beanstalk/checker"
-	"gopkg.in/yaml.v2"
 )
 
 var crnl = []byte{'\r', '\n'}
 
+type ClientOptions struct {
+	Logger Logger
+	// rejects blocking reserve commands with ErrBlockingCommand while other commands
+	// are in flight and any command while a blocking reserve is in flight
+	Concurrent bool
+	// limits the time to read a response, reserve-with-timeout extends it by its own
+	// timeout and a blocking reserve is not limited
+	ReadTimeout time.Duration
+	// limits the time to write a request
+	WriteTimeout time.Duration
+	// compresses put payloads larger than CompressThreshold, consumers decompress them transparently
+	Compressor Compressor
+	// is 1024 bytes by default
+	CompressThreshold int
+	// keeps put payloads the server rejects as too big, or larger than BlobThreshold,
+	// and puts reference jobs instead, consumers fetch and delete them transparently
+	BlobStore BlobStore
+	// offloads payloads larger than it without a round trip when positive
+	BlobThreshold int
+}
+
+// Client is safe for concurrent use, commands of concurrent callers are written
+// and answered in order on the single connection. Note the used and watched tubes
+// are shared by all callers.
 type Client struct {
+	options   *ClientOptions
+	rwc       io.ReadWriteCloser
 	conn      *textproto.Conn
 	checker   *checker.Checker
 	createdAt time.Time
 	usedAt    int64
 	closedAt  int64
+	inFlight  int
+	blocking  bool
+	blobs     map[int]string
+	mutex     sync.Mutex
 }
 
 func Dial(address string) (*Client, error) {
-	conn, err := net.Dial("tcp", address)
-	if err != nil {
-		return nil, err
-	}
-
-	return NewClient(conn), nil
+	return DialWithOptions(address, &DialOptions{})
 }
 
 func NewClient(conn io.ReadWriteCloser) *Client {
+	return NewClientWithOptions(conn, &ClientOptions{})
+}
+
+func NewClientWithOptions(conn io.ReadWriteCloser, options *ClientOptions) *Client {
+	// the options are shared by the clients of a dialer, so the defaults go into a copy
+	copied := *options
+	options = &copied
+
+	if options.Logger == nil {
+		options.Logger = NopLogger
+	}
+
+	if options.ReadTimeout < 0 {
+		options.ReadTimeout = 0
+	}
+
+	if options.WriteTimeout < 0 {
+		options.WriteTimeout = 0
+	}
+
+	if options.CompressThreshold <= 0 {
+		options.CompressThreshold = 1024
+	}
+
 	return &Client{
+		options:   options,
+		rwc:       conn,
 		conn:      textproto.NewConn(conn),
 		checker:   checker.New(conn),
 		createdAt: time.Now(),
 		usedAt:    0,
 		closedAt:  0,
+		blobs:     map[int]string{},
 	}
 }
 
@@ -59,303 +113,373 @@ func (c *Client) Check() error {
 }
 
 func (c *Client) Close() error {
-	atomic.StoreInt64(&c.closedAt, time.Now().Unix())
+	if !atomic.CompareAndSwapInt64(&c.closedAt, 0, time.Now().Unix()) {
+		return nil
+	}
 
 	return c.conn.Close()
 }
 
 func (c *Client) Put(priority uint32, delay, ttr time.Duration, data []byte) (int, error) {
-	r, err := c.ExecuteCommand(PutCommand{Priority: priority, Delay: delay, TTR: ttr, Data: data})
-	if err != nil {
-		return 0, err
-	}
+	return c.PutContext(context.Background(), priority, delay, ttr, data)
+}
 
-	return r.(PutCommandResponse).ID, nil
+func (c *Client) PutContext(ctx context.Context, priority uint32, delay, ttr time.Duration, data []byte) (int, error) {
+	return executePut(ctx, c, priority, delay, ttr, data)
 }
 
 func (c *Client) Use(tube string) (string, error) {
-	r, err := c.ExecuteCommand(UseCommand{Tube: tube})
-	if err != nil {
-		return "", err
-	}
+	return c.UseContext(context.Background(), tube)
+}
 
-	return r.(UseCommandResponse).Tube, nil
+func (c *Client) UseContext(ctx context.Context, tube string) (string, error) {
+	return executeUse(ctx, c, tube)
 }
 
 func (c *Client) Reserve() (*Job, error) {
-	r, err := c.ExecuteCommand(ReserveCommand{})
-	if err != nil {
-		return nil, err
-	}
+	return c.ReserveContext(context.Background())
+}
 
-	return &Job{ID: r.(ReserveCommandResponse).ID, Data: r.(ReserveCommandResponse).Data}, nil
+func (c *Client) ReserveContext(ctx context.Context) (*Job, error) {
+	return executeReserve(ctx, c)
 }
 
 func (c *Client) ReserveWithTimeout(timeout time.Duration) (*Job, error) {
-	r, err := c.ExecuteCommand(ReserveWithTimeoutCommand{Timeout: timeout})
-	if err != nil {
-		return nil, err
-	}
+	return c.ReserveWithTimeoutContext(context.Background(), timeout)
+}
 
-	return &Job{ID: r.(ReserveWithTimeoutCommandResponse).ID, Data: r.(ReserveWithTimeoutCommandResponse).Data}, nil
+func (c *Client) ReserveWithTimeoutContext(ctx context.Context, timeout time.Duration) (*Job, error) {
+	return executeReserveWithTimeout(ctx, c, timeout)
 }
 
 func (c *Client) ReserveJob(id int) (*Job, error) {
-	r, err := c.ExecuteCommand(ReserveJobCommand{ID: id})
-	if err != nil {
-		return nil, err
-	}
+	return c.ReserveJobContext(context.Background(), id)
+}
 
-	return &Job{ID: r.(ReserveJobCommandResponse).ID, Data: r.(ReserveJobCommandResponse).Data}, nil
+func (c *Client) ReserveJobContext(ctx context.Context, id int) (*Job, error) {
+	return executeReserveJob(ctx, c, id)
 }
 
 func (c *Client) Delete(id int) error {
-	_, err := c.ExecuteCommand(DeleteCommand{ID: id})
+	return c.DeleteContext(context.Background(), id)
+}
 
-	return err
+func (c *Client) DeleteContext(ctx context.Context, id int) error {
+	return executeDelete(ctx, c, id)
 }
 
 func (c *Client) Release(id int, priority uint32, delay time.Duration) error {
-	_, err := c.ExecuteCommand(ReleaseCommand{ID: id, Priority: priority, Delay: delay})
+	return c.ReleaseContext(context.Background(), id, priority, delay)
+}
 
-	return err
+func (c *Client) ReleaseContext(ctx context.Context, id int, priority uint32, delay time.Duration) error {
+	return executeRelease(ctx, c, id, priority, delay)
 }
 
 func (c *Client) Bury(id int, priority uint32) error {
-	_, err := c.ExecuteCommand(BuryCommand{ID: id, Priority: priority})
+	return c.BuryContext(context.Background(), id, priority)
+}
 
-	return err
+func (c *Client) BuryContext(ctx context.Context, id int, priority uint32) error {
+	return executeBury(ctx, c, id, priority)
 }
 
 func (c *Client) Touch(id int) error {
-	_, err := c.ExecuteCommand(TouchCommand{ID: id})
+	return c.TouchContext(context.Background(), id)
+}
 
-	return err
+func (c *Client) TouchContext(ctx context.Context, id int) error {
+	return executeTouch(ctx, c, id)
 }
 
 func (c *Client) Watch(tube string) (int, error) {
-	r, err := c.ExecuteCommand(WatchCommand{Tube: tube})
-	if err != nil {
-		return 0, err
-	}
+	return c.WatchContext(context.Background(), tube)
+}
 
-	return r.(WatchCommandResponse).Count, nil
+func (c *Client) WatchContext(ctx context.Context, tube string) (int, error) {
+	return executeWatch(ctx, c, tube)
 }
 
 func (c *Client) Ignore(tube string) (int, error) {
-	r, err := c.ExecuteCommand(IgnoreCommand{Tube: tube})
-	if err != nil {
-		return 0, err
-	}
+	return c.IgnoreContext(context.Background(), tube)
+}
 
-	return r.(IgnoreCommandResponse).Count, nil
+func (c *Client) IgnoreContext(ctx context.Context, tube string) (int, error) {
+	return executeIgnore(ctx, c, tube)
 }
 
 func (c *Client) Peek(id int) (*Job, error) {
-	r, err := c.ExecuteCommand(PeekCommand{ID: id})
-	if err != nil {
-		return nil, err
-	}
+	return c.PeekContext(context.Background(), id)
+}
 
-	return &Job{ID: r.(PeekCommandResponse).ID, Data: r.(PeekCommandResponse).Data}, nil
+func (c *Client) PeekContext(ctx context.Context, id int) (*Job, error) {
+	return executePeek(ctx, c, id)
 }
 
 func (c *Client) PeekReady() (*Job, error) {
-	r, err := c.ExecuteCommand(PeekReadyCommand{})
-	if err != nil {
-		return nil, err
-	}
+	return c.PeekReadyContext(context.Background())
+}
 
-	return &Job{ID: r.(PeekReadyCommandResponse).ID, Data: r.(PeekReadyCommandResponse).Data}, nil
+func (c *Client) PeekReadyContext(ctx context.Context) (*Job, error) {
+	return executePeekReady(ctx, c)
 }
 
 func (c *Client) PeekDelayed() (*Job, error) {
-	r, err := c.ExecuteCommand(PeekDelayedCommand{})
-	if err != nil {
-		return nil, err
-	}
+	return c.PeekDelayedContext(context.Background())
+}
 
-	return &Job{ID: r.(PeekDelayedCommandResponse).ID, Data: r.(PeekDelayedCommandResponse).Data}, nil
+func (c *Client) PeekDelayedContext(ctx context.Context) (*Job, error) {
+	return executePeekDelayed(ctx, c)
 }
 
 func (c *Client) PeekBuried() (*Job, error) {
-	r, err := c.ExecuteCommand(PeekBuriedCommand{})
-	if err != nil {
-		return nil, err
-	}
+	return c.PeekBuriedContext(context.Background())
+}
 
-	return &Job{ID: r.(PeekBuriedCommandResponse).ID, Data: r.(PeekBuriedCommandResponse).Data}, nil
+func (c *Client) PeekBuriedContext(ctx context.Context) (*Job, error) {
+	return executePeekBuried(ctx, c)
 }
 
 func (c *Client) Kick(bound int) (int, error) {
-	r, err := c.ExecuteCommand(KickCommand{Bound: bound})
-	if err != nil {
-		return 0, err
-	}
+	return c.KickContext(context.Background(), bound)
+}
 
-	return r.(KickCommandResponse).Count, nil
+func (c *Client) KickContext(ctx context.Context, bound int) (int, error) {
+	return executeKick(ctx, c, bound)
 }
 
 func (c *Client) KickJob(id int) error {
-	_, err := c.ExecuteCommand(KickJobCommand{ID: id})
+	return c.KickJobContext(context.Background(), id)
+}
 
-	return err
+func (c *Client) KickJobContext(ctx context.Context, id int) error {
+	return executeKickJob(ctx, c, id)
 }
 
 func (c *Client) StatsJob(id int) (*StatsJob, error) {
-	r, err := c.ExecuteCommand(StatsJobCommand{ID: id})
-	if err != nil {
-		return nil, err
-	}
-
-	var stats StatsJob
-	if err = yaml.Unmarshal(r.(StatsJobCommandResponse).Data, &stats); err != nil {
-		return nil, err
-	}
+	return c.StatsJobContext(context.Background(), id)
+}
 
-	return &stats, err
+func (c *Client) StatsJobContext(ctx context.Context, id int) (*StatsJob, error) {
+	return executeStatsJob(ctx, c, id)
 }
 
 func (c *Client) StatsTube(tube string) (*StatsTube, error) {
-	r, err := c.ExecuteCommand(StatsTubeCommand{Tube: tube})
-	if err != nil {
-		return nil, err
-	}
-
-	var stats StatsTube
-	if err = yaml.Unmarshal(r.(StatsTubeCommandResponse).Data, &stats); err != nil {
-		return nil, err
-	}
+	return c.StatsTubeContext(context.Background(), tube)
+}
 
-	return &stats, err
+func (c *Client) StatsTubeContext(ctx context.Context, tube string) (*StatsTube, error) {
+	return executeStatsTube(ctx, c, tube)
 }
 
 func (c *Client) Stats() (*Stats, error) {
-	r, err := c.ExecuteCommand(StatsCommand{})
-	if err != nil {
-		return nil, err
-	}
-
-	var stats Stats
-	if err = yaml.Unmarshal(r.(StatsCommandResponse).Data, &stats); err != nil {
-		return nil, err
-	}
+	return c.StatsContext(context.Background())
+}
 
-	return &stats, err
+func (c *Client) StatsContext(ctx context.Context) (*Stats, error) {
+	return executeStats(ctx, c)
 }
 
 func (c *Client) ListTubes() ([]string, error) {
-	r, err := c.ExecuteCommand(ListTubesCommand{})
-	if err != nil {
-		return nil, err
-	}
-
-	var tubes []string
-	if err = yaml.Unmarshal(r.(ListTubesCommandResponse).Data, &tubes); err != nil {
-		return nil, err
-	}
+	return c.ListTubesContext(context.Background())
+}
 
-	return tubes, nil
+func (c *Client) ListTubesContext(ctx context.Context) ([]string, error) {
+	return executeListTubes(ctx, c)
 }
 
 func (c *Client) ListTubeUsed() (string, error) {
-	r, err := c.ExecuteCommand(ListTubeUsedCommand{})
-	if err != nil {
-		return "", err
-	}
+	return c.ListTubeUsedContext(context.Background())
+}
 
-	return r.(ListTubeUsedCommandResponse).Tube, nil
+func (c *Client) ListTubeUsedContext(ctx context.Context) (string, error) {
+	return executeListTubeUsed(ctx, c)
 }
 
 func (c *Client) ListTubesWatched() ([]string, error) {
-	r, err := c.ExecuteCommand(ListTubesWatchedCommand{})
+	return c.ListTubesWatchedContext(context.Background())
+}
+
+func (c *Client) ListTubesWatchedContext(ctx context.Context) ([]string, error) {
+	return executeListTubesWatched(ctx, c)
+}
+
+func (c *Client) PauseTube(tube string, delay time.Duration) error {
+	return c.PauseTubeContext(context.Background(), tube, delay)
+}
+
+func (c *Client) PauseTubeContext(ctx context.Context, tube string, delay time.Duration) error {
+	return executePauseTube(ctx, c, tube, delay)
+}
+
+func (c *Client) ExecuteCommand(command Command) (CommandResponse, error) {
+	return c.ExecuteCommandContext(context.Background(), command)
+}
+
+func (c *Client) ExecuteCommandContext(ctx context.Context, command Command) (CommandResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	command = c.compress(command)
+
+	command, key, err := c.offload(ctx, command, false)
 	if err != nil {
 		return nil, err
 	}
 
-	var tubes []string
-	if err = yaml.Unmarshal(r.(ListTubesWatchedCommandResponse).Data, &tubes); err != nil {
+	response, err := c.execute(ctx, command)
+	if err == ErrJobTooBig && key == "" {
+		if command, key, err = c.offload(ctx, command, true); err != nil {
+			return nil, err
+		}
+
+		if key != "" {
+			response, err = c.execute(ctx, command)
+		} else {
+			err = ErrJobTooBig
+		}
+	}
+
+	if err != nil {
+		if isRejected(err) {
+			c.discardBlob(key)
+		}
+
 		return nil, err
 	}
 
-	return tubes, nil
+	return c.claim(ctx, command, response)
 }
 
-func (c *Client) PauseTube(tube string, delay time.Duration) error {
-	_, err := c.ExecuteCommand(PauseTubeCommand{Tube: tube, Delay: delay})
+func (c *Client) execute(ctx context.Context, command Command) (CommandResponse, error) {
+	if err := c.acquire(command); err != nil {
+		return nil, err
+	}
 
-	return err
-}
+	defer c.release()
 
-func (c *Client) ExecuteCommand(command Command) (CommandResponse, error) {
 	atomic.StoreInt64(&c.usedAt, time.Now().Unix())
 
-	id, err := c.writeRequest(command.CommandLine(), command.Body())
-	if err != nil {
-		return nil, err
+	id := c.conn.Next()
+
+	if err := c.writeRequest(ctx, id, command); err != nil {
+		c.skipResponse(id)
+
+		return nil, c.abandon(ctx, err)
 	}
 
-	responseLine, body, err := c.readResponse(id, command.HasResponseBody())
+	responses, err := c.readResponse(ctx, id, command)
 	if err != nil {
-		return nil, err
+		return nil, c.abandon(ctx, err)
 	}
 
+	return buildResponse(command, responses[0])
+}
+
+type rawResponse struct {
+	line string
+	body []byte
+}
+
+func buildResponse(command Command, response rawResponse) (CommandResponse, error) {
 	switch {
-	case strings.EqualFold(responseLine, "OUT_OF_MEMORY"):
+	case strings.EqualFold(response.line, "OUT_OF_MEMORY"):
 		return nil, ErrOutOfMemory
 
-	case strings.EqualFold(responseLine, "INTERNAL_ERROR"):
+	case strings.EqualFold(response.line, "INTERNAL_ERROR"):
 		return nil, ErrInternalError
 
-	case strings.EqualFold(responseLine, "BAD_FORMAT"):
+	case strings.EqualFold(response.line, "BAD_FORMAT"):
 		return nil, ErrBadFormat
 
-	case strings.EqualFold(responseLine, "UNKNOWN_COMMAND"):
+	case strings.EqualFold(response.line, "UNKNOWN_COMMAND"):
 		return nil, ErrUnknownCommand
 	}
 
 	if builder, ok := command.(CommandResponseBuilder); ok {
-		return builder.BuildResponse(responseLine, body)
+		return builder.BuildResponse(response.line, response.body)
 	}
 
 	return nil, ErrMalformedCommand
 }
 
-func (c *Client) writeRequest(line string, body []byte) (uint, error) {
-	id := c.conn.Next()
-
+// writeRequest writes the commands as a single request, so they are sent back-to-back.
+func (c *Client) writeRequest(ctx context.Context, id uint, commands ...Command) error {
 	c.conn.StartRequest(id)
 	defer c.conn.EndRequest(id)
 
+	deadline := c.startDeadline(ctx, c.setWriteDeadline)
+	defer deadline.stop()
+
+	for _, command := range commands {
+		deadline.reset(c.options.WriteTimeout)
+
+		if err := c.writeCommand(command.CommandLine(), command.Body()); err != nil {
+			return deadline.timeoutError(err, "write", c.options.WriteTimeout)
+		}
+	}
+
+	if err := c.conn.W.Flush(); err != nil {
+		return deadline.timeoutError(err, "write", c.options.WriteTimeout)
+	}
+
+	return nil
+}
+
+func (c *Client) writeCommand(line string, body []byte) error {
 	if _, err := c.conn.W.Write([]byte(line)); err != nil {
-		return 0, err
+		return err
 	}
 
 	if _, err := c.conn.W.Write(crnl); err != nil {
-		return 0, err
+		return err
 	}
 
 	if body != nil {
 		if _, err := c.conn.W.Write(body); err != nil {
-			return 0, err
+			return err
 		}
 
 		if _, err := c.conn.W.Write(crnl); err != nil {
-			return 0, err
+			return err
 		}
 	}
 
-	if err := c.conn.W.Flush(); err != nil {
-		return 0, err
-	}
-
-	return id, nil
+	return nil
 }
 
-func (c *Client) readResponse(id uint, hasBody bool) (string, []byte, error) {
-	c.conn.StartResponse(id)
+// readResponse reads one response per command, on failure the responses read so far are returned.
+func (c *Client) readResponse(ctx context.Context, id uint, commands ...Command) ([]rawResponse, error) {
+	waited := c.startResponse(ctx, id)
 	defer c.conn.EndResponse(id)
 
+	if !waited {
+		return nil, ctx.Err()
+	}
+
+	deadline := c.startDeadline(ctx, c.setReadDeadline)
+	defer deadline.stop()
+
+	responses := make([]rawResponse, 0, len(commands))
+
+	for _, command := range commands {
+		timeout := c.readTimeout(command)
+
+		deadline.reset(timeout)
+
+		line, body, err := c.readCommandResponse(command.HasResponseBody())
+		if err != nil {
+			return responses, deadline.timeoutError(err, "read", timeout)
+		}
+
+		responses = append(responses, rawResponse{line: line, body: body})
+	}
+
+	return responses, nil
+}
+
+func (c *Client) readCommandResponse(hasBody bool) (string, []byte, error) {
 	line, err := c.conn.ReadLine()
 	if err != nil {
 		return line, nil, err
@@ -383,3 +507,108 @@ func (c *Client) readResponse(id uint, hasBody bool) (string, []byte, error) {
 
 	return line, body, nil
 }
+
+// acquire registers the commands as in flight, in the concurrent mode it rejects
+// commands that would wait for, or make others wait for, a blocking reserve.
+func (c *Client) acquire(commands ...Command) error {
+	if !c.options.Concurrent {
+		return nil
+	}
+
+	blocking := false
+	for _, command := range commands {
+		blocking = blocking || isBlockingCommand(command)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.blocking || (blocking && c.inFlight > 0) {
+		return ErrBlockingCommand
+	}
+
+	c.inFlight++
+	c.blocking = blocking
+
+	return nil
+}
+
+func (c *Client) release() {
+	if !c.options.Concurrent {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.inFlight--
+	c.blocking = false
+}
+
+func isBlockingCommand(command Command) bool {
+	switch command := command.(type) {
+	case ReserveCommand:
+		return true
+
+	case ReserveWithTimeoutCommand:
+		return command.Timeout > 0
+
+	default:
+		return false
+	}
+}
+
+func (c *Client) readTimeout(command Command) time.Duration {
+	if c.options.ReadTimeout == 0 {
+		return 0
+	}
+
+	switch command := command.(type) {
+	case ReserveCommand:
+		return 0
+
+	case ReserveWithTimeoutCommand:
+		return c.options.ReadTimeout + command.Timeout
+
+	default:
+		return c.options.ReadTimeout
+	}
+}
+
+// startResponse waits for the turn to read the response, e.g. behind a blocking reserve, and
+// reports whether the context was still alive. A context ending meanwhile closes the client,
+// which fails the response being read, so the turn comes.
+func (c *Client) startResponse(ctx context.Context, id uint) bool {
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.Close()
+	})
+
+	c.conn.StartResponse(id)
+
+	return stop()
+}
+
+// skipResponse passes the turn of a request that was not written completely,
+// so the responses of subsequent requests are not blocked.
+func (c *Client) skipResponse(id uint) {
+	c.conn.StartResponse(id)
+	c.conn.EndResponse(id)
+}
+
+// abandon closes the client after a request or a response was interrupted
+// mid-stream, since the protocol state of the connection is unknown.
+func (c *Client) abandon(ctx context.Context, err error) error {
+	var timeoutErr *TimeoutError
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		err = ctxErr
+	} else if errors.As(err, &timeoutErr) {
+		c.options.Logger.Log(ErrorLogLevel, "Closes client after timeout", map[string]interface{}{"error": err})
+	} else if _, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) {
+		err = context.DeadlineExceeded
+	}
+
+	_ = c.Close()
+
+	return err
+}
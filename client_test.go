@@ -1,12 +1,20 @@
 package beanstalk_test
 
 import (
+	"bufio"
+	"context"
+	"fmt"
 	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/artiifact/go-beanstalk"
 	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,6 +71,17 @@ func TestDefaultClient_Put(t *testing.T) {
 		require.NoError(t, c.Close())
 	})
 
+	t.Run("empty", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn([]string{"put 1 0 60 0\r\n\r\n"}, []string{"INSERTED 1\r\n"}))
+
+		id, err := c.Put(1, 0, 1*time.Minute, nil)
+
+		require.Nil(t, err)
+		require.Equal(t, 1, id)
+
+		require.NoError(t, c.Close())
+	})
+
 	t.Run("buried", func(t *testing.T) {
 		c := beanstalk.NewClient(mock.NewConn([]string{"put 100 0 1800 11\r\ntest buried\r\n"}, []string{"BURIED 1\r\n"}))
 
@@ -1451,6 +1470,327 @@ func TestDefaultClient_ExecuteCommand(t *testing.T) {
 	})
 }
 
+func TestDefaultClient_ExecuteCommandContext(t *testing.T) {
+	t.Run("canceled before write", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(nil, nil))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := c.ExecuteCommandContext(ctx, mockCommand{})
+
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, int64(0), c.ClosedAt().Unix())
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("deadline exceeded", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			_, _ = io.Copy(io.Discard, serverConn)
+		}()
+
+		c := beanstalk.NewClient(clientConn)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		defer cancel()
+
+		job, err := c.ReserveContext(ctx)
+
+		require.Equal(t, context.DeadlineExceeded, err)
+		require.Nil(t, job)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("canceled while reading", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			_, _ = io.Copy(io.Discard, serverConn)
+		}()
+
+		c := beanstalk.NewClient(clientConn)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		job, err := c.ReserveContext(ctx)
+
+		require.Equal(t, context.Canceled, err)
+		require.Nil(t, job)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("deadline exceeded behind a blocking reserve", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			_, _ = io.Copy(io.Discard, serverConn)
+		}()
+
+		c := beanstalk.NewClient(clientConn)
+
+		reserveErrCh := make(chan error, 1)
+
+		go func() {
+			_, err := c.Reserve()
+
+			reserveErrCh <- err
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+
+		defer cancel()
+
+		start := time.Now()
+
+		_, err := c.PutContext(ctx, 1, 0, 1*time.Minute, []byte("test"))
+
+		require.Equal(t, context.DeadlineExceeded, err)
+		require.Less(t, time.Since(start), 1*time.Second)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+
+		// the reserve fails on the closed client
+		select {
+		case err := <-reserveErrCh:
+			require.Error(t, err)
+
+		case <-time.After(1 * time.Second):
+			t.Fatal("reserve is still blocked")
+		}
+	})
+
+	t.Run("success", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			buffer := make([]byte, 64)
+
+			if _, err := serverConn.Read(buffer); err != nil {
+				return
+			}
+
+			_, _ = serverConn.Write([]byte("RESERVED 1 4\r\ntest\r\n"))
+		}()
+
+		c := beanstalk.NewClient(clientConn)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+		defer cancel()
+
+		job, err := c.ReserveContext(ctx)
+
+		require.Nil(t, err)
+		require.Equal(t, 1, job.ID)
+		require.Equal(t, []byte("test"), job.Data)
+		require.Equal(t, int64(0), c.ClosedAt().Unix())
+
+		require.NoError(t, c.Close())
+	})
+}
+
+func TestDefaultClient_Concurrent(t *testing.T) {
+	t.Run("concurrent callers", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		// replies with the job id taken from the body
+		go func() {
+			r := bufio.NewReader(serverConn)
+
+			for {
+				if _, err := r.ReadString('\n'); err != nil {
+					return
+				}
+
+				body, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if _, err = fmt.Fprintf(serverConn, "INSERTED %s\r\n", strings.TrimSpace(body)); err != nil {
+					return
+				}
+			}
+		}()
+
+		c := beanstalk.NewClientWithOptions(clientConn, &beanstalk.ClientOptions{Concurrent: true})
+
+		var wg sync.WaitGroup
+
+		for i := 1; i <= 50; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				id, err := c.Put(0, 0, time.Minute, []byte(strconv.Itoa(i)))
+
+				assert.Nil(t, err)
+				assert.Equal(t, i, id)
+			}(i)
+		}
+
+		wg.Wait()
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("blocking command", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			_, _ = io.Copy(io.Discard, serverConn)
+		}()
+
+		c := beanstalk.NewClientWithOptions(clientConn, &beanstalk.ClientOptions{Concurrent: true})
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			_, err := c.ReserveContext(ctx)
+
+			errCh <- err
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+
+		// reserve is in flight
+		_, err := c.Put(0, 0, time.Minute, []byte("test"))
+
+		require.Equal(t, beanstalk.ErrBlockingCommand, err)
+
+		_, err = c.Pipeline().Add(beanstalk.DeleteCommand{ID: 1}).Execute()
+
+		require.Equal(t, beanstalk.ErrBlockingCommand, err)
+
+		cancel()
+
+		require.Equal(t, context.Canceled, <-errCh)
+	})
+
+	t.Run("non-blocking reserve", func(t *testing.T) {
+		c := beanstalk.NewClientWithOptions(
+			mock.NewConn([]string{"reserve-with-timeout 0\r\n"}, []string{"TIMED_OUT\r\n"}),
+			&beanstalk.ClientOptions{Concurrent: true},
+		)
+
+		_, err := c.ReserveWithTimeout(0)
+
+		require.Equal(t, beanstalk.ErrTimedOut, err)
+
+		require.NoError(t, c.Close())
+	})
+}
+
+func TestDefaultClient_Timeouts(t *testing.T) {
+	t.Run("read timeout", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			_, _ = io.Copy(io.Discard, serverConn)
+		}()
+
+		c := beanstalk.NewClientWithOptions(clientConn, &beanstalk.ClientOptions{ReadTimeout: 50 * time.Millisecond})
+
+		_, err := c.Stats()
+
+		var timeoutErr *beanstalk.TimeoutError
+
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, "read", timeoutErr.Op)
+		require.True(t, timeoutErr.Timeout())
+		require.Equal(t, "beanstalk: read timeout after 50ms", err.Error())
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("write timeout", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		c := beanstalk.NewClientWithOptions(clientConn, &beanstalk.ClientOptions{WriteTimeout: 50 * time.Millisecond})
+
+		_, err := c.Put(0, 0, time.Minute, []byte("test"))
+
+		var timeoutErr *beanstalk.TimeoutError
+
+		require.ErrorAs(t, err, &timeoutErr)
+		require.Equal(t, "write", timeoutErr.Op)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("context deadline first", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			_, _ = io.Copy(io.Discard, serverConn)
+		}()
+
+		c := beanstalk.NewClientWithOptions(clientConn, &beanstalk.ClientOptions{ReadTimeout: 1 * time.Minute})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		defer cancel()
+
+		_, err := c.StatsContext(ctx)
+
+		require.Equal(t, context.DeadlineExceeded, err)
+	})
+
+	t.Run("reserve with timeout", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			buffer := make([]byte, 64)
+
+			if _, err := serverConn.Read(buffer); err != nil {
+				return
+			}
+
+			// replies later than the read timeout, but within the reserve timeout
+			time.Sleep(200 * time.Millisecond)
+
+			_, _ = serverConn.Write([]byte("TIMED_OUT\r\n"))
+		}()
+
+		c := beanstalk.NewClientWithOptions(clientConn, &beanstalk.ClientOptions{ReadTimeout: 50 * time.Millisecond})
+
+		_, err := c.ReserveWithTimeout(1 * time.Second)
+
+		require.Equal(t, beanstalk.ErrTimedOut, err)
+		require.Equal(t, int64(0), c.ClosedAt().Unix())
+
+		require.NoError(t, c.Close())
+	})
+}
+
 // mock command
 
 type mockCommand struct{}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+)
+
+type command struct {
+	args    string
+	help    string
+	minArgs int
+	maxArgs int
+	// registers the flags of the subcommand, read by run from env.flags
+	flags func(fs *flag.FlagSet)
+	// returns the value to print, nothing is printed for nil
+	run func(ctx context.Context, e *env, args []string) (interface{}, error)
+}
+
+// job is the printed form of a job, the payload is printed as text.
+type job struct {
+	ID   int    `json:"id" yaml:"id"`
+	Data string `json:"data" yaml:"data"`
+}
+
+type inserted struct {
+	ID int `json:"id" yaml:"id"`
+}
+
+type kicked struct {
+	Count int `json:"count" yaml:"count"`
+}
+
+type exported struct {
+	Count int `json:"exported" yaml:"exported"`
+}
+
+type moved struct {
+	ID    int    `json:"id" yaml:"id"`
+	NewID int    `json:"newId" yaml:"new-id"`
+	State string `json:"state" yaml:"state"`
+}
+
+type buried struct {
+	ID  int `json:"id" yaml:"id"`
+	Age int `json:"age" yaml:"age"`
+	// is counted like BuriedFilter.MinBuries, without the buries of inspections
+	Buries   int    `json:"buries" yaml:"buries"`
+	Priority int    `json:"priority" yaml:"pri"`
+	Data     string `json:"data" yaml:"data"`
+}
+
+type imported struct {
+	Count int `json:"imported" yaml:"imported"`
+}
+
+var commands = map[string]*command{
+	"put": {
+		args:    "[data]",
+		help:    "Put a job into the tube, the data is read from stdin unless given",
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			priority := uint32Value(1024)
+			fs.Var(&priority, "priority", "priority, lower is more urgent")
+			fs.Duration("delay", 0, "delay before the job is ready")
+			fs.Duration("ttr", 1*time.Minute, "time to run")
+		},
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			var data []byte
+
+			if len(args) == 1 {
+				data = []byte(args[0])
+			} else {
+				var err error
+				if data, err = io.ReadAll(e.stdin); err != nil {
+					return nil, err
+				}
+			}
+
+			id, err := e.client.PutContext(ctx, e.uint32("priority"), e.duration("delay"), e.duration("ttr"), data)
+			if err != nil {
+				return nil, err
+			}
+
+			return inserted{ID: id}, nil
+		},
+	},
+	"peek": {
+		args:    "<id>",
+		help:    "Show a job",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			id, err := parseID(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return printJob(e.client.PeekContext(ctx, id))
+		},
+	},
+	"peek-ready": {
+		help: "Show the next ready job of the tube",
+		run: func(ctx context.Context, e *env, _ []string) (interface{}, error) {
+			return printJob(e.client.PeekReadyContext(ctx))
+		},
+	},
+	"peek-delayed": {
+		help: "Show the delayed job of the tube with the shortest delay left",
+		run: func(ctx context.Context, e *env, _ []string) (interface{}, error) {
+			return printJob(e.client.PeekDelayedContext(ctx))
+		},
+	},
+	"peek-buried": {
+		help: "Show the next buried job of the tube",
+		run: func(ctx context.Context, e *env, _ []string) (interface{}, error) {
+			return printJob(e.client.PeekBuriedContext(ctx))
+		},
+	},
+	"kick": {
+		args:    "<bound>",
+		help:    "Kick at most bound buried or delayed jobs of the tube",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			bound, err := strconv.Atoi(args[0])
+			if err != nil || bound <= 0 {
+				return nil, fmt.Errorf("invalid bound %q", args[0])
+			}
+
+			count, err := e.client.KickContext(ctx, bound)
+			if err != nil {
+				return nil, err
+			}
+
+			return kicked{Count: count}, nil
+		},
+	},
+	"kick-job": {
+		args:    "<id>",
+		help:    "Kick a buried or delayed job",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			id, err := parseID(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, e.client.KickJobContext(ctx, id)
+		},
+	},
+	"delete": {
+		args:    "<id>",
+		help:    "Delete a job",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			id, err := parseID(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, e.client.DeleteContext(ctx, id)
+		},
+	},
+	"stats": {
+		help: "Show the server statistics",
+		run: func(ctx context.Context, e *env, _ []string) (interface{}, error) {
+			return e.client.StatsContext(ctx)
+		},
+	},
+	"stats-tube": {
+		args:    "[tube]",
+		help:    "Show the statistics of a tube, --tube by default",
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			tube := e.options.tube
+			if len(args) == 1 {
+				tube = args[0]
+			}
+
+			return e.client.StatsTubeContext(ctx, tube)
+		},
+	},
+	"stats-job": {
+		args:    "<id>",
+		help:    "Show the statistics of a job",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			id, err := parseID(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return e.client.StatsJobContext(ctx, id)
+		},
+	},
+	"list-tubes": {
+		help: "List the existing tubes",
+		run: func(ctx context.Context, e *env, _ []string) (interface{}, error) {
+			return e.client.ListTubesContext(ctx)
+		},
+	},
+	"top": {
+		help:  "Show the statistics of all tubes, refreshed periodically",
+		flags: topFlags,
+		run:   runTop,
+	},
+	"export": {
+		args:    "[file]",
+		help:    "Export the ready, delayed and buried jobs of the tube as JSON Lines, to stdout unless given",
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("delete", false, "delete the exported jobs instead of restoring them")
+		},
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			options := &beanstalk.ExportOptions{Delete: e.bool("delete")}
+
+			if len(args) == 0 {
+				_, err := beanstalk.ExportTube(ctx, e.client, e.options.tube, e.stdout, options)
+
+				return nil, err
+			}
+
+			f, err := os.Create(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			count, err := beanstalk.ExportTube(ctx, e.client, e.options.tube, f, options)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			return exported{Count: count}, nil
+		},
+	},
+	"import": {
+		args:    "[file]",
+		help:    "Import jobs exported as JSON Lines, from stdin unless given",
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.String("into", "", "tube to import into instead of the exported tubes")
+		},
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			r := e.stdin
+
+			if len(args) == 1 {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return nil, err
+				}
+
+				defer f.Close()
+
+				r = f
+			}
+
+			count, err := beanstalk.ImportJobs(ctx, e.client, r, &beanstalk.ImportOptions{Tube: e.string("into")})
+			if err != nil {
+				return nil, err
+			}
+
+			return imported{Count: count}, nil
+		},
+	},
+	"move": {
+		args:    "<tube>",
+		help:    "Move the jobs of the tube to another tube, keeping their state and priority",
+		minArgs: 1,
+		maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.Int("limit", 0, "maximum number of jobs, all if zero")
+			fs.String("state", "", "comma separated states to move, ready, delayed and buried by default")
+			fs.String("contains", "", "move only jobs whose data contains the text")
+			fs.Bool("copy", false, "keep the original jobs")
+			fs.Bool("dry-run", false, "list the jobs without moving them")
+		},
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			options := &beanstalk.MoveOptions{
+				Limit:  e.int("limit"),
+				Copy:   e.bool("copy"),
+				DryRun: e.bool("dry-run"),
+			}
+
+			if state := e.string("state"); state != "" {
+				options.States = strings.Split(state, ",")
+			}
+
+			if contains := e.string("contains"); contains != "" {
+				options.Filter = func(job *beanstalk.Job, _ *beanstalk.StatsJob) bool {
+					return strings.Contains(string(job.Data), contains)
+				}
+			}
+
+			jobs, err := beanstalk.MoveJobs(ctx, e.client, e.options.tube, args[0], options)
+
+			rows := make([]moved, len(jobs))
+			for i, job := range jobs {
+				rows[i] = moved{ID: job.ID, NewID: job.NewID, State: job.State}
+			}
+
+			if err != nil {
+				// reports the jobs moved before the failure
+				_ = formats[e.options.output](e.stdout, rows)
+
+				return nil, err
+			}
+
+			return rows, nil
+		},
+	},
+	"buried": {
+		args:    "<list|kick|delete|export> [file]",
+		help:    "Inspect all buried jobs of the tube and kick, delete or export the matching ones",
+		minArgs: 1,
+		maxArgs: 2,
+		flags: func(fs *flag.FlagSet) {
+			fs.String("contains", "", "match only jobs whose data contains the text")
+			fs.Duration("min-age", 0, "match only jobs at least this old")
+			fs.Int("min-buries", 0, "match only jobs buried at least this many times")
+			fs.Int("limit", 0, "maximum number of matching jobs, all if zero")
+		},
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			filter := &beanstalk.BuriedFilter{
+				MinAge:    e.duration("min-age"),
+				MinBuries: e.int("min-buries"),
+				Limit:     e.int("limit"),
+			}
+
+			if contains := e.string("contains"); contains != "" {
+				filter.Data = func(data []byte) bool {
+					return strings.Contains(string(data), contains)
+				}
+			}
+
+			m := beanstalk.NewBuriedManager(e.client, e.options.tube)
+
+			if len(args) == 2 && args[0] != "export" {
+				return nil, fmt.Errorf("unexpected argument %q", args[1])
+			}
+
+			var (
+				report *beanstalk.BuriedReport
+				err    error
+			)
+
+			switch args[0] {
+			case "list":
+				report, err = m.List(ctx, filter)
+
+			case "kick":
+				report, err = m.Kick(ctx, filter)
+
+			case "delete":
+				report, err = m.Delete(ctx, filter)
+
+			case "export":
+				if len(args) == 1 {
+					_, err = m.Export(ctx, filter, e.stdout)
+
+					return nil, err
+				}
+
+				f, createErr := os.Create(args[1])
+				if createErr != nil {
+					return nil, createErr
+				}
+
+				report, err = m.Export(ctx, filter, f)
+				if closeErr := f.Close(); err == nil {
+					err = closeErr
+				}
+
+			default:
+				return nil, fmt.Errorf("unknown action %q", args[0])
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			rows := make([]buried, len(report.Jobs))
+			for i, job := range report.Jobs {
+				rows[i] = buried{
+					ID:       job.ID,
+					Age:      job.Stats.Age,
+					Buries:   job.Stats.Kicks + 1,
+					Priority: job.Stats.Priority,
+					Data:     string(job.Data),
+				}
+			}
+
+			return rows, nil
+		},
+	},
+	"pause-tube": {
+		args:    "<delay>",
+		help:    "Pause the tube for the delay, e.g. 30s",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, e *env, args []string) (interface{}, error) {
+			delay, err := time.ParseDuration(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return nil, e.client.PauseTubeContext(ctx, e.options.tube, delay)
+		},
+	},
+}
+
+func printJob(j *beanstalk.Job, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return job{ID: j.ID, Data: string(j.Data)}, nil
+}
+
+func parseID(s string) (int, error) {
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid job id %q", s)
+	}
+
+	return id, nil
+}
+
+func (e *env) uint32(name string) uint32 {
+	return e.flags.Lookup(name).Value.(flag.Getter).Get().(uint32)
+}
+
+func (e *env) bool(name string) bool {
+	return e.flags.Lookup(name).Value.(flag.Getter).Get().(bool)
+}
+
+func (e *env) string(name string) string {
+	return e.flags.Lookup(name).Value.String()
+}
+
+func (e *env) int(name string) int {
+	return e.flags.Lookup(name).Value.(flag.Getter).Get().(int)
+}
+
+func (e *env) duration(name string) time.Duration {
+	return e.flags.Lookup(name).Value.(flag.Getter).Get().(time.Duration)
+}
+
+// uint32Value is a flag value that rejects numbers out of the 32 bit range of priorities.
+type uint32Value uint32
+
+func (v *uint32Value) Set(s string) error {
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return err.(*strconv.NumError).Err
+	}
+
+	*v = uint32Value(n)
+
+	return nil
+}
+
+func (v *uint32Value) String() string {
+	return strconv.FormatUint(uint64(*v), 10)
+}
+
+func (v *uint32Value) Get() interface{} {
+	return uint32(*v)
+}
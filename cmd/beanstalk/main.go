@@ -0,0 +1,192 @@
+// Command beanstalk administers a beanstalkd server with one subcommand per protocol
+// command, e.g.
+//
+//	beanstalk --tube emails stats-tube
+//	echo '{"to":"a@example.com"}' | beanstalk --tube emails put --ttr 1m
+//	beanstalk --output json peek-buried
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+)
+
+const defaultAddr = "127.0.0.1:11300"
+
+// options are accepted before and after the subcommand.
+type options struct {
+	addr    string
+	tube    string
+	output  string
+	timeout time.Duration
+}
+
+func (o *options) register(fs *flag.FlagSet) {
+	addr := os.Getenv("BEANSTALK_ADDR")
+	if addr == "" {
+		addr = defaultAddr
+	}
+
+	fs.StringVar(&o.addr, "addr", addr, "server address, $BEANSTALK_ADDR by default")
+	fs.StringVar(&o.tube, "tube", "default", "tube to use")
+	fs.StringVar(&o.output, "output", "table", "output format: json, yaml or table")
+	fs.DurationVar(&o.timeout, "timeout", 5*time.Second, "dial, read and write timeout")
+}
+
+// env is passed to subcommands.
+type env struct {
+	options *options
+	client  *beanstalk.Client
+	flags   *flag.FlagSet
+	stdin   io.Reader
+	stdout  io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line and returns the exit code, 2 for usage errors.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	o := &options{}
+
+	fs := flag.NewFlagSet("beanstalk", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		usage(fs, stderr)
+	}
+
+	o.register(fs)
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+
+		return 2
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "beanstalk: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+
+		return 2
+	}
+
+	sub := flag.NewFlagSet("beanstalk "+fs.Arg(0), flag.ContinueOnError)
+	sub.SetOutput(stderr)
+	sub.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: beanstalk [flags] %s %s\n\n%s.\n\nFlags:\n", fs.Arg(0), cmd.args, cmd.help)
+		sub.PrintDefaults()
+	}
+
+	// keeps the values given before the subcommand, registering resets them
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	o.register(sub)
+
+	for name, value := range given {
+		_ = sub.Set(name, value)
+	}
+
+	if cmd.flags != nil {
+		cmd.flags(sub)
+	}
+
+	if err := sub.Parse(fs.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+
+		return 2
+	}
+
+	if sub.NArg() < cmd.minArgs || sub.NArg() > cmd.maxArgs {
+		sub.Usage()
+
+		return 2
+	}
+
+	format, ok := formats[o.output]
+	if !ok {
+		fmt.Fprintf(stderr, "beanstalk: unknown output format %q\n", o.output)
+
+		return 2
+	}
+
+	c, err := beanstalk.DialContext(ctx, o.addr, &beanstalk.DialOptions{
+		Timeout: o.timeout,
+		Client: &beanstalk.ClientOptions{
+			ReadTimeout:  o.timeout,
+			WriteTimeout: o.timeout,
+		},
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "beanstalk: %s\n", err)
+
+		return 1
+	}
+
+	defer c.Close()
+
+	if _, err := c.UseContext(ctx, o.tube); err != nil {
+		fmt.Fprintf(stderr, "beanstalk: %s\n", err)
+
+		return 1
+	}
+
+	result, err := cmd.run(ctx, &env{options: o, client: c, flags: sub, stdin: stdin, stdout: stdout}, sub.Args())
+	if err == nil && result != nil {
+		err = format(stdout, result)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "beanstalk: %s\n", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func usage(fs *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "Usage: beanstalk [flags] <command> [args]\n\nCommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].help)
+	}
+
+	fmt.Fprintf(w, "\nFlags:\n")
+	fs.PrintDefaults()
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// execute runs the command line against the server and returns the exit code and output.
+func execute(t *testing.T, s *beanstalktest.Server, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), append([]string{"--addr", s.Addr}, args...), strings.NewReader(stdin), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	t.Run("Put", func(t *testing.T) {
+		code, stdout, stderr := execute(t, s, "", "--tube", "emails", "put", "--priority", "1", "--ttr", "30s", "hello")
+
+		require.Equal(t, 0, code, stderr)
+		require.Equal(t, "id  1\n", stdout)
+
+		code, stdout, _ = execute(t, s, "from stdin", "put", "--tube", "emails", "--output", "json")
+
+		require.Equal(t, 0, code)
+		require.JSONEq(t, `{"id": 2}`, stdout)
+
+		code, stdout, _ = execute(t, s, "", "--output", "yaml", "stats-job", "1")
+
+		require.Equal(t, 0, code)
+
+		var stats map[string]interface{}
+
+		require.NoError(t, yaml.Unmarshal([]byte(stdout), &stats))
+		require.Equal(t, "emails", stats["tube"])
+		require.EqualValues(t, 1, stats["pri"])
+		require.EqualValues(t, 30, stats["ttr"])
+	})
+
+	t.Run("Peek", func(t *testing.T) {
+		code, stdout, _ := execute(t, s, "", "--output", "json", "peek", "2")
+
+		require.Equal(t, 0, code)
+		require.JSONEq(t, `{"id": 2, "data": "from stdin"}`, stdout)
+
+		code, stdout, _ = execute(t, s, "", "--tube", "emails", "peek-ready")
+
+		require.Equal(t, 0, code)
+		require.Equal(t, "id    1\ndata  hello\n", stdout)
+
+		code, _, stderr := execute(t, s, "", "--tube", "emails", "peek-buried")
+
+		require.Equal(t, 1, code)
+		require.Equal(t, "beanstalk: "+beanstalk.ErrNotFound.Error()+"\n", stderr)
+	})
+
+	t.Run("Kick", func(t *testing.T) {
+		c, err := beanstalk.Dial(s.Addr)
+
+		require.NoError(t, err)
+
+		defer c.Close()
+
+		_, err = c.Watch("emails")
+
+		require.NoError(t, err)
+
+		job, err := c.Reserve()
+
+		require.NoError(t, err)
+		require.NoError(t, c.Bury(job.ID, 1))
+
+		code, stdout, _ := execute(t, s, "", "--tube", "emails", "--output", "json", "peek-buried")
+
+		require.Equal(t, 0, code)
+		require.JSONEq(t, `{"id": 1, "data": "hello"}`, stdout)
+
+		code, stdout, _ = execute(t, s, "", "--tube", "emails", "--output", "json", "kick", "10")
+
+		require.Equal(t, 0, code)
+		require.JSONEq(t, `{"count": 1}`, stdout)
+
+		require.NoError(t, c.Bury(mustReserve(t, c), 1))
+
+		code, stdout, _ = execute(t, s, "", "kick-job", "1")
+
+		require.Equal(t, 0, code)
+		require.Empty(t, stdout)
+	})
+
+	t.Run("Tubes", func(t *testing.T) {
+		code, stdout, _ := execute(t, s, "", "list-tubes")
+
+		require.Equal(t, 0, code)
+		require.Equal(t, "default\nemails\n", stdout)
+
+		code, _, _ = execute(t, s, "", "--tube", "emails", "pause-tube", "1m")
+
+		require.Equal(t, 0, code)
+
+		code, stdout, _ = execute(t, s, "", "--output", "json", "stats-tube", "emails")
+
+		require.Equal(t, 0, code)
+
+		var stats beanstalk.StatsTube
+
+		require.NoError(t, json.Unmarshal([]byte(stdout), &stats))
+		require.Equal(t, "emails", stats.Name)
+		require.Equal(t, 2, stats.CurrentJobsReady)
+		require.Equal(t, 60, stats.Pause)
+
+		code, stdout, _ = execute(t, s, "", "stats")
+
+		require.Equal(t, 0, code)
+		require.Contains(t, stdout, "current-jobs-ready")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		code, stdout, _ := execute(t, s, "", "delete", "2")
+
+		require.Equal(t, 0, code)
+		require.Empty(t, stdout)
+
+		code, _, stderr := execute(t, s, "", "delete", "2")
+
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, beanstalk.ErrNotFound.Error())
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		code, _, stderr := execute(t, s, "")
+
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, "Usage: beanstalk")
+
+		code, _, stderr = execute(t, s, "", "unknown")
+
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, `unknown command "unknown"`)
+
+		code, _, stderr = execute(t, s, "", "peek")
+
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, "Usage: beanstalk [flags] peek <id>")
+
+		code, _, stderr = execute(t, s, "", "--output", "xml", "stats")
+
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, `unknown output format "xml"`)
+
+		code, _, stderr = execute(t, s, "", "put", "--priority", "4294967296", "hello")
+
+		require.Equal(t, 2, code)
+		require.Contains(t, stderr, "value out of range")
+
+		code, _, stderr = execute(t, s, "", "peek", "abc")
+
+		require.Equal(t, 1, code)
+		require.Contains(t, stderr, `invalid job id "abc"`)
+	})
+}
+
+func mustReserve(t *testing.T, c *beanstalk.Client) int {
+	t.Helper()
+
+	job, err := c.Reserve()
+
+	require.NoError(t, err)
+
+	return job.ID
+}
+
+func TestRun_ExportImport(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	for _, data := range []string{"first", "second"} {
+		code, _, _ := execute(t, s, "", "--tube", "emails", "put", data)
+
+		require.Equal(t, 0, code)
+	}
+
+	code, stdout, stderr := execute(t, s, "", "--tube", "emails", "export")
+
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, 2, strings.Count(stdout, "\n"))
+	require.Contains(t, stdout, `"state":"ready"`)
+
+	file := t.TempDir() + "/emails.jsonl"
+
+	code, out, _ := execute(t, s, "", "--tube", "emails", "--output", "json", "export", "--delete", file)
+
+	require.Equal(t, 0, code)
+	require.JSONEq(t, `{"exported": 2}`, out)
+
+	code, _, _ = execute(t, s, "", "--tube", "emails", "peek-ready")
+
+	require.Equal(t, 1, code)
+
+	code, out, _ = execute(t, s, "", "--output", "json", "import", "--into", "restored", file)
+
+	require.Equal(t, 0, code)
+	require.JSONEq(t, `{"imported": 2}`, out)
+
+	code, out, _ = execute(t, s, stdout, "--output", "json", "import")
+
+	require.Equal(t, 0, code)
+	require.JSONEq(t, `{"imported": 2}`, out)
+
+	code, out, _ = execute(t, s, "", "--output", "json", "stats-tube", "restored")
+
+	require.Equal(t, 0, code)
+
+	var stats beanstalk.StatsTube
+
+	require.NoError(t, json.Unmarshal([]byte(out), &stats))
+	require.Equal(t, 2, stats.CurrentJobsReady)
+
+	code, _, stderr = execute(t, s, "", "import", t.TempDir()+"/missing.jsonl")
+
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "no such file")
+}
+
+func TestRun_Move(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	for _, data := range []string{"keep", "move me", "move me too"} {
+		code, _, _ := execute(t, s, "", "--tube", "emails", "put", data)
+
+		require.Equal(t, 0, code)
+	}
+
+	code, stdout, stderr := execute(t, s, "", "--tube", "emails", "move", "--contains", "move", "--dry-run", "archive")
+
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, "ID  NEW-ID  STATE\n2   0       ready\n3   0       ready\n", stdout)
+
+	code, stdout, _ = execute(t, s, "", "--tube", "emails", "--output", "json", "move", "--contains", "move", "--limit", "1", "archive")
+
+	require.Equal(t, 0, code)
+	require.JSONEq(t, `[{"id": 2, "newId": 4, "state": "ready"}]`, stdout)
+
+	code, stdout, _ = execute(t, s, "", "--tube", "archive", "--output", "json", "peek-ready")
+
+	require.Equal(t, 0, code)
+	require.JSONEq(t, `{"id": 4, "data": "move me"}`, stdout)
+
+	code, _, stderr = execute(t, s, "", "--tube", "emails", "move", "--state", "ready,unknown", "archive")
+
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, `unknown state "unknown"`)
+}
+
+func TestRun_Buried(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	for _, data := range []string{"invoice 1", "report 2", "invoice 3"} {
+		id, err := c.Put(10, 0, 30*time.Second, []byte(data))
+
+		require.NoError(t, err)
+
+		_, err = c.ReserveJob(id)
+
+		require.NoError(t, err)
+		require.NoError(t, c.Bury(id, 10))
+	}
+
+	code, stdout, stderr := execute(t, s, "", "buried", "list")
+
+	require.Equal(t, 0, code, stderr)
+	require.Equal(t, 4, strings.Count(stdout, "\n"))
+	require.True(t, strings.HasPrefix(stdout, "ID  AGE  BURIES  PRI  DATA\n"))
+
+	code, stdout, _ = execute(t, s, "", "buried", "--contains", "report", "export")
+
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, `"id":2`)
+
+	code, stdout, _ = execute(t, s, "", "--output", "json", "buried", "--contains", "invoice", "--limit", "1", "kick")
+
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, `"id": 1`)
+
+	code, stdout, _ = execute(t, s, "", "--output", "json", "buried", "--contains", "invoice", "delete")
+
+	require.Equal(t, 0, code)
+	require.Contains(t, stdout, `"id": 3`)
+
+	stats, err := c.StatsTube("default")
+
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.CurrentJobsReady)
+	require.Equal(t, 1, stats.CurrentJobsBuried)
+
+	code, _, stderr = execute(t, s, "", "buried", "purge")
+
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, `unknown action "purge"`)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v2"
+)
+
+var formats = map[string]func(w io.Writer, v interface{}) error{
+	"json":  writeJSON,
+	"yaml":  writeYAML,
+	"table": writeTable,
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+// writeTable prints a struct as name and value rows, a slice of structs as rows under
+// a header and other slices one element per line. Names are taken from the yaml tags,
+// so they match the protocol.
+func writeTable(w io.Writer, v interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+
+	var elem reflect.Type
+	if rv.Kind() == reflect.Slice {
+		if elem = rv.Type().Elem(); elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+	}
+
+	switch {
+	case rv.Kind() == reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			fmt.Fprintf(tw, "%s\t%v\n", columnName(rv.Type().Field(i)), rv.Field(i).Interface())
+		}
+
+	case elem != nil && elem.Kind() == reflect.Struct:
+		columns := make([]string, elem.NumField())
+		for i := range columns {
+			columns[i] = strings.ToUpper(columnName(elem.Field(i)))
+		}
+
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+
+		for i := 0; i < rv.Len(); i++ {
+			row := reflect.Indirect(rv.Index(i))
+
+			for j := 0; j < row.NumField(); j++ {
+				columns[j] = fmt.Sprint(row.Field(j).Interface())
+			}
+
+			fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		}
+
+	case rv.Kind() == reflect.Slice:
+		for i := 0; i < rv.Len(); i++ {
+			fmt.Fprintln(tw, rv.Index(i).Interface())
+		}
+
+	default:
+		fmt.Fprintln(tw, v)
+	}
+
+	return tw.Flush()
+}
+
+func columnName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("yaml"), ","); name != "" {
+		return name
+	}
+
+	return strings.ToLower(f.Name)
+}
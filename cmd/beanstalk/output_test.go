@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTable(t *testing.T) {
+	type row struct {
+		Name  string `yaml:"name"`
+		Ready int    `yaml:"current-jobs-ready"`
+	}
+
+	for _, tc := range []struct {
+		name     string
+		v        interface{}
+		expected string
+	}{
+		{"struct", row{"default", 1}, "name                default\ncurrent-jobs-ready  1\n"},
+		{"pointer", &row{"default", 1}, "name                default\ncurrent-jobs-ready  1\n"},
+		{"rows", []*row{{"default", 1}, {"emails", 20}}, "NAME     CURRENT-JOBS-READY\ndefault  1\nemails   20\n"},
+		{"list", []string{"default", "emails"}, "default\nemails\n"},
+		{"value", 1, "1\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			require.NoError(t, writeTable(&b, tc.v))
+			require.Equal(t, tc.expected, b.String())
+		})
+	}
+}
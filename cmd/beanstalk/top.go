@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+)
+
+// clears the terminal and moves the cursor home before each table frame
+const clearScreen = "\x1b[H\x1b[2J"
+
+// rate is printed with one decimal in tables.
+type rate float64
+
+func (r rate) String() string {
+	return fmt.Sprintf("%.1f", float64(r))
+}
+
+type tubeRow struct {
+	Name     string `json:"name" yaml:"name"`
+	Ready    int    `json:"ready" yaml:"ready"`
+	Buried   int    `json:"buried" yaml:"buried"`
+	Waiting  int    `json:"waiting" yaml:"waiting"`
+	Reserved int    `json:"reserved" yaml:"reserved"`
+	Delayed  int    `json:"delayed" yaml:"delayed"`
+	Watching int    `json:"watching" yaml:"watching"`
+	// is derived from TotalJobs
+	Puts rate `json:"putsPerSecond" yaml:"puts/s"`
+	// is derived from CmdDelete
+	Deletes rate `json:"deletesPerSecond" yaml:"deletes/s"`
+	Pause   int  `json:"pauseTimeLeft" yaml:"pause"`
+}
+
+type frame struct {
+	Time        time.Time `json:"time" yaml:"time"`
+	Version     string    `json:"version" yaml:"version"`
+	Uptime      int       `json:"uptime" yaml:"uptime"`
+	Connections int       `json:"connections" yaml:"connections"`
+	Tubes       []tubeRow `json:"tubes" yaml:"tubes"`
+}
+
+// sample holds the tube statistics of one poll, rates are computed between two samples.
+type sample struct {
+	at    time.Time
+	tubes map[string]*beanstalk.StatsTube
+}
+
+func topFlags(fs *flag.FlagSet) {
+	fs.Duration("interval", 2*time.Second, "refresh interval")
+	fs.Int("count", 0, "number of frames, until interrupted if zero")
+}
+
+// runTop polls the statistics of every tube and renders a frame per interval, tables
+// refresh the terminal while json and yaml frames are appended.
+func runTop(ctx context.Context, e *env, _ []string) (interface{}, error) {
+	interval := e.duration("interval")
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s", interval)
+	}
+
+	count := e.int("count")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *sample
+
+	for n := 1; ; n++ {
+		stats, cur, err := poll(ctx, e.client)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+
+			return nil, err
+		}
+
+		f := &frame{
+			Time:        cur.at,
+			Version:     stats.Version,
+			Uptime:      stats.Uptime,
+			Connections: stats.CurrentConnections,
+			Tubes:       rows(prev, cur),
+		}
+
+		if err := render(e.stdout, e.options.output, f); err != nil {
+			return nil, err
+		}
+
+		if count > 0 && n >= count {
+			return nil, nil
+		}
+
+		prev = cur
+
+		select {
+		case <-ctx.Done():
+			return nil, nil
+
+		case <-ticker.C:
+		}
+	}
+}
+
+func poll(ctx context.Context, c *beanstalk.Client) (*beanstalk.Stats, *sample, error) {
+	stats, err := c.StatsContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names, err := c.ListTubesContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s := &sample{at: time.Now(), tubes: make(map[string]*beanstalk.StatsTube, len(names))}
+
+	for _, name := range names {
+		tube, err := c.StatsTubeContext(ctx, name)
+		if errors.Is(err, beanstalk.ErrNotFound) {
+			// the tube was removed after listing
+			continue
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s.tubes[name] = tube
+	}
+
+	return stats, s, nil
+}
+
+// rows sorts the tubes by ready, buried and waiting counts, the rates of tubes missing
+// from the previous sample are zero.
+func rows(prev, cur *sample) []tubeRow {
+	rows := make([]tubeRow, 0, len(cur.tubes))
+
+	for name, tube := range cur.tubes {
+		row := tubeRow{
+			Name:     name,
+			Ready:    tube.CurrentJobsReady,
+			Buried:   tube.CurrentJobsBuried,
+			Waiting:  tube.CurrentWaiting,
+			Reserved: tube.CurrentJobsReserved,
+			Delayed:  tube.CurrentJobsDelayed,
+			Watching: tube.CurrentWatching,
+			Pause:    tube.PauseTimeLeft,
+		}
+
+		if prev != nil {
+			if last, ok := prev.tubes[name]; ok {
+				elapsed := cur.at.Sub(prev.at).Seconds()
+
+				row.Puts = perSecond(tube.TotalJobs-last.TotalJobs, elapsed)
+				row.Deletes = perSecond(tube.CmdDelete-last.CmdDelete, elapsed)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+
+		switch {
+		case a.Ready != b.Ready:
+			return a.Ready > b.Ready
+
+		case a.Buried != b.Buried:
+			return a.Buried > b.Buried
+
+		case a.Waiting != b.Waiting:
+			return a.Waiting > b.Waiting
+
+		default:
+			return a.Name < b.Name
+		}
+	})
+
+	return rows
+}
+
+func perSecond(delta int, elapsed float64) rate {
+	if delta <= 0 || elapsed <= 0 {
+		return 0
+	}
+
+	return rate(float64(delta) / elapsed)
+}
+
+func render(w io.Writer, output string, f *frame) error {
+	if output != "table" {
+		return formats[output](w, f)
+	}
+
+	fmt.Fprintf(w, "%sbeanstalkd %s  up %s  %d connections  %s\n\n",
+		clearScreen, f.Version, time.Duration(f.Uptime)*time.Second, f.Connections, f.Time.Format(time.TimeOnly))
+
+	return writeTable(w, f.Tubes)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRows(t *testing.T) {
+	at := time.Now()
+
+	prev := &sample{at: at, tubes: map[string]*beanstalk.StatsTube{
+		"emails": {TotalJobs: 10, CmdDelete: 4},
+	}}
+
+	cur := &sample{at: at.Add(2 * time.Second), tubes: map[string]*beanstalk.StatsTube{
+		"default": {CurrentJobsReady: 1, CurrentJobsBuried: 5},
+		"emails":  {CurrentJobsReady: 5, TotalJobs: 30, CmdDelete: 5},
+		"reports": {CurrentJobsReady: 1, CurrentJobsBuried: 5, CurrentWaiting: 2, TotalJobs: 100},
+		"idle":    {},
+	}}
+
+	require.Equal(t, []tubeRow{
+		{Name: "emails", Ready: 5, Puts: 10, Deletes: 0.5},
+		{Name: "reports", Ready: 1, Buried: 5, Waiting: 2},
+		{Name: "default", Ready: 1, Buried: 5},
+		{Name: "idle"},
+	}, rows(prev, cur))
+
+	require.Equal(t, "0.5", rate(0.5).String())
+}
+
+func TestRun_Top(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	code, _, _ := execute(t, s, "", "--tube", "emails", "put", "test")
+
+	require.Equal(t, 0, code)
+
+	code, stdout, stderr := execute(t, s, "", "top", "--count", "2", "--interval", "10ms", "--output", "json")
+
+	require.Equal(t, 0, code, stderr)
+
+	dec := json.NewDecoder(strings.NewReader(stdout))
+
+	for i := 0; i < 2; i++ {
+		var f frame
+
+		require.NoError(t, dec.Decode(&f))
+		require.Len(t, f.Tubes, 2)
+		require.Equal(t, "emails", f.Tubes[0].Name)
+		require.Equal(t, 1, f.Tubes[0].Ready)
+	}
+
+	var f frame
+
+	require.ErrorIs(t, dec.Decode(&f), io.EOF)
+
+	code, stdout, _ = execute(t, s, "", "top", "--count", "1")
+
+	require.Equal(t, 0, code)
+	require.True(t, strings.HasPrefix(stdout, clearScreen+"beanstalkd "))
+	require.Contains(t, stdout, "NAME     READY  BURIED")
+	require.Contains(t, stdout, "emails   1")
+
+	code, _, stderr = execute(t, s, "", "top", "--interval", "0s")
+
+	require.Equal(t, 1, code)
+	require.Contains(t, stderr, "invalid interval")
+}
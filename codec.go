@@ -0,0 +1,172 @@
+package beanstalk
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Codec converts job payloads to and from values. Formats without a codec in this
+// package, e.g. msgpack, are supported by implementing it.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+	// BinaryCodec supports values implementing encoding.BinaryMarshaler, or Marshal and
+	// Unmarshal methods like generated protobuf and msgpack types.
+	BinaryCodec Codec = binaryCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type binaryCodec struct{}
+
+func (binaryCodec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case encoding.BinaryMarshaler:
+		return m.MarshalBinary()
+
+	case interface{ Marshal() ([]byte, error) }:
+		return m.Marshal()
+
+	default:
+		return nil, fmt.Errorf("beanstalk: codec: %T does not implement encoding.BinaryMarshaler", v)
+	}
+}
+
+func (binaryCodec) Unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case encoding.BinaryUnmarshaler:
+		return m.UnmarshalBinary(data)
+
+	case interface{ Unmarshal(data []byte) error }:
+		return m.Unmarshal(data)
+
+	default:
+		return fmt.Errorf("beanstalk: codec: %T does not implement encoding.BinaryUnmarshaler", v)
+	}
+}
+
+type PutOptions struct {
+	Priority uint32
+	Delay    time.Duration
+	TTR      time.Duration
+	// is JSONCodec by default
+	Codec Codec
+	// wraps the payload in an envelope when not nil, see EncodeEnvelope
+	Headers Headers
+}
+
+// PutValue encodes the value with the codec of the options and puts it as a job.
+func PutValue[T any](c *Client, options *PutOptions, v T) (int, error) {
+	return PutValueContext(context.Background(), c, options, v)
+}
+
+func PutValueContext[T any](ctx context.Context, c *Client, options *PutOptions, v T) (int, error) {
+	if options == nil {
+		options = &PutOptions{}
+	}
+
+	codec := codecOrDefault(options.Codec)
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+
+	if options.Headers != nil {
+		if data, err = seal(options.Headers, codec, data); err != nil {
+			return 0, err
+		}
+	}
+
+	return c.PutContext(ctx, options.Priority, options.Delay, options.TTR, data)
+}
+
+// Decode decodes the body of the job with the built-in codec named by the Content-Type
+// header, JSONCodec otherwise.
+func (j *Job) Decode(v interface{}) error {
+	contentType := j.Headers().Get(HeaderContentType)
+
+	codec := JSONCodec
+
+	for _, c := range []Codec{GobCodec, BinaryCodec} {
+		if c.(ContentTyper).ContentType() == contentType {
+			codec = c
+		}
+	}
+
+	return j.DecodeWith(codec, v)
+}
+
+func (j *Job) DecodeWith(codec Codec, v interface{}) error {
+	body := j.Data
+
+	if j.headers == nil {
+		var err error
+		if _, body, err = openEnvelope(j.Data); err != nil {
+			return err
+		}
+	}
+
+	return codecOrDefault(codec).Unmarshal(body, v)
+}
+
+// TypedHandler decodes the body of every job with the codec, as Decode does if nil, and
+// passes the value to fn. Payloads that fail to decode are buried as permanent errors.
+func TypedHandler[T any](codec Codec, fn func(ctx context.Context, v T) error) Handler {
+	return HandlerFunc(func(ctx context.Context, job *Job) error {
+		decode := job.Decode
+		if codec != nil {
+			decode = func(v interface{}) error {
+				return job.DecodeWith(codec, v)
+			}
+		}
+
+		var v T
+		if err := decode(&v); err != nil {
+			return Permanent(fmt.Errorf("beanstalk: codec: failed to decode job %d: %w", job.ID, err))
+		}
+
+		return fn(ctx, v)
+	})
+}
+
+func codecOrDefault(codec Codec) Codec {
+	if codec == nil {
+		return JSONCodec
+	}
+
+	return codec
+}
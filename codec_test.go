@@ -0,0 +1,160 @@
+package beanstalk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type email struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+}
+
+// version implements encoding.BinaryMarshaler like generated binary formats do.
+type version struct {
+	Major, Minor byte
+}
+
+func (v version) MarshalBinary() ([]byte, error) {
+	return []byte{v.Major, v.Minor}, nil
+}
+
+func (v *version) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errors.New("invalid version")
+	}
+
+	v.Major, v.Minor = data[0], data[1]
+
+	return nil
+}
+
+func TestCodec(t *testing.T) {
+	testCases := []struct {
+		name     string
+		codec    beanstalk.Codec
+		value    interface{}
+		target   interface{}
+		expected interface{}
+	}{
+		{
+			name:     "json",
+			codec:    beanstalk.JSONCodec,
+			value:    email{To: "a@example.com", Subject: "test"},
+			target:   &email{},
+			expected: &email{To: "a@example.com", Subject: "test"},
+		},
+		{
+			name:     "gob",
+			codec:    beanstalk.GobCodec,
+			value:    email{To: "a@example.com", Subject: "test"},
+			target:   &email{},
+			expected: &email{To: "a@example.com", Subject: "test"},
+		},
+		{
+			name:     "binary",
+			codec:    beanstalk.BinaryCodec,
+			value:    version{Major: 1, Minor: 2},
+			target:   &version{},
+			expected: &version{Major: 1, Minor: 2},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			data, err := testCase.codec.Marshal(testCase.value)
+
+			require.NoError(t, err)
+
+			require.NoError(t, testCase.codec.Unmarshal(data, testCase.target))
+			require.Equal(t, testCase.expected, testCase.target)
+		})
+	}
+
+	t.Run("binary / unsupported", func(t *testing.T) {
+		_, err := beanstalk.BinaryCodec.Marshal(email{})
+
+		require.EqualError(t, err, "beanstalk: codec: beanstalk_test.email does not implement encoding.BinaryMarshaler")
+		require.Error(t, beanstalk.BinaryCodec.Unmarshal(nil, &email{}))
+	})
+}
+
+func TestPutValue(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"put 1 0 60 39\r\n{\"to\":\"a@example.com\",\"subject\":\"test\"}\r\n"},
+			[]string{"INSERTED 1\r\n"},
+		))
+
+		id, err := beanstalk.PutValue(c, &beanstalk.PutOptions{Priority: 1, TTR: 1 * time.Minute}, email{To: "a@example.com", Subject: "test"})
+
+		require.Nil(t, err)
+		require.Equal(t, 1, id)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"put 0 0 0 2\r\n\x01\x02\r\n"},
+			[]string{"INSERTED 2\r\n"},
+		))
+
+		id, err := beanstalk.PutValue(c, &beanstalk.PutOptions{Codec: beanstalk.BinaryCodec}, version{Major: 1, Minor: 2})
+
+		require.Nil(t, err)
+		require.Equal(t, 2, id)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("marshal failure", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(nil, nil))
+
+		_, err := beanstalk.PutValue(c, nil, func() {})
+
+		require.Error(t, err)
+
+		require.NoError(t, c.Close())
+	})
+}
+
+func TestJob_Decode(t *testing.T) {
+	job := &beanstalk.Job{ID: 1, Data: []byte(`{"to":"a@example.com","subject":"test"}`)}
+
+	var v email
+
+	require.NoError(t, job.Decode(&v))
+	require.Equal(t, email{To: "a@example.com", Subject: "test"}, v)
+
+	var ver version
+
+	require.NoError(t, (&beanstalk.Job{Data: []byte{1, 2}}).DecodeWith(beanstalk.BinaryCodec, &ver))
+	require.Equal(t, version{Major: 1, Minor: 2}, ver)
+}
+
+func TestTypedHandler(t *testing.T) {
+	var received email
+
+	handler := beanstalk.TypedHandler(nil, func(ctx context.Context, v email) error {
+		received = v
+
+		return nil
+	})
+
+	err := handler.ServeJob(context.Background(), &beanstalk.Job{ID: 1, Data: []byte(`{"to":"a@example.com"}`)})
+
+	require.NoError(t, err)
+	require.Equal(t, email{To: "a@example.com"}, received)
+
+	err = handler.ServeJob(context.Background(), &beanstalk.Job{ID: 2, Data: []byte("invalid")})
+
+	require.True(t, beanstalk.IsPermanent(err))
+	require.Contains(t, err.Error(), "beanstalk: codec: failed to decode job 2")
+}
@@ -22,7 +22,12 @@ func (c PutCommand) CommandLine() string {
 	return fmt.Sprintf("put %d %0.f %0.f %d", c.Priority, c.Delay.Seconds(), c.TTR.Seconds(), len(c.Data))
 }
 
+// Body is never nil, so empty jobs are terminated as well.
 func (c PutCommand) Body() []byte {
+	if c.Data == nil {
+		return []byte{}
+	}
+
 	return c.Data
 }
 
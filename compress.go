@@ -0,0 +1,148 @@
+package beanstalk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// is set on enveloped jobs whose body is compressed
+const HeaderContentEncoding = "Content-Encoding"
+
+var ErrUnknownEncoding = errors.New("beanstalk: envelope: unknown content encoding")
+
+// Compressor compresses job bodies. Compressors of other formats, e.g. zstd, are made
+// available to consumers by RegisterCompressor.
+type Compressor interface {
+	// is the name recorded in the Content-Encoding header, e.g. "gzip"
+	Encoding() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var GzipCompressor Compressor = gzipCompressor{}
+
+var (
+	compressors      = map[string]Compressor{GzipCompressor.Encoding(): GzipCompressor}
+	compressorsMutex sync.RWMutex
+)
+
+// RegisterCompressor makes the compressor available to decompress bodies of its encoding.
+func RegisterCompressor(compressor Compressor) {
+	compressorsMutex.Lock()
+	defer compressorsMutex.Unlock()
+
+	compressors[compressor.Encoding()] = compressor
+}
+
+func lookupCompressor(encoding string) (Compressor, bool) {
+	compressorsMutex.RLock()
+	defer compressorsMutex.RUnlock()
+
+	compressor, ok := compressors[encoding]
+
+	return compressor, ok
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// compress replaces the payload of a put larger than the threshold with a compressed
+// envelope, keeping the headers of an enveloped payload. Payloads that do not shrink
+// are put as is.
+func (c *Client) compress(command Command) Command {
+	put, ok := command.(PutCommand)
+	if !ok || c.options.Compressor == nil || len(put.Data) <= c.options.CompressThreshold {
+		return command
+	}
+
+	headers, body, err := DecodeEnvelope(put.Data)
+	if err != nil || headers.Get(HeaderContentEncoding) != "" {
+		return command
+	}
+
+	compressed, err := c.options.Compressor.Compress(body)
+	if err != nil {
+		c.options.Logger.Log(WarningLogLevel, "Failed to compress job payload", map[string]interface{}{"error": err})
+
+		return command
+	}
+
+	sealed := make(Headers, len(headers)+1)
+	for key, value := range headers {
+		sealed[key] = value
+	}
+
+	sealed[HeaderContentEncoding] = c.options.Compressor.Encoding()
+
+	data, err := EncodeEnvelope(sealed, compressed)
+	if err != nil || len(data) >= len(put.Data) {
+		return command
+	}
+
+	c.options.Logger.Log(DebugLogLevel, "Compressed job payload", map[string]interface{}{
+		"encoding":       c.options.Compressor.Encoding(),
+		"size":           len(put.Data),
+		"compressedSize": len(data),
+	})
+
+	put.Data = data
+
+	return put
+}
+
+// openEnvelope splits the payload like DecodeEnvelope and decompresses the body.
+func openEnvelope(data []byte) (Headers, []byte, error) {
+	headers, body, err := DecodeEnvelope(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encoding := headers.Get(HeaderContentEncoding)
+	if encoding == "" {
+		return headers, body, nil
+	}
+
+	compressor, ok := lookupCompressor(encoding)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrUnknownEncoding, encoding)
+	}
+
+	if body, err = compressor.Decompress(body); err != nil {
+		return nil, nil, err
+	}
+
+	return headers, body, nil
+}
@@ -0,0 +1,175 @@
+package beanstalk_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	level beanstalk.LogLevel
+	msg   string
+	args  map[string]interface{}
+}
+
+type recordingLogger struct {
+	entries []logEntry
+	mutex   sync.Mutex
+}
+
+func (l *recordingLogger) Log(level beanstalk.LogLevel, msg string, args map[string]interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = append(l.entries, logEntry{level, msg, args})
+}
+
+// reverseCompressor is a stand-in for compressors registered by applications.
+type reverseCompressor struct{}
+
+func (reverseCompressor) Encoding() string {
+	return "reverse"
+}
+
+func (reverseCompressor) Compress(data []byte) ([]byte, error) {
+	return reverse(data[:len(data)/2]), nil
+}
+
+func (reverseCompressor) Decompress(data []byte) ([]byte, error) {
+	return bytes.Repeat(reverse(data), 2), nil
+}
+
+func reverse(data []byte) []byte {
+	reversed := make([]byte, len(data))
+	for i, b := range data {
+		reversed[len(data)-1-i] = b
+	}
+
+	return reversed
+}
+
+func TestGzipCompressor(t *testing.T) {
+	data := []byte(strings.Repeat("test", 100))
+
+	compressed, err := beanstalk.GzipCompressor.Compress(data)
+
+	require.NoError(t, err)
+	require.Less(t, len(compressed), len(data))
+
+	decompressed, err := beanstalk.GzipCompressor.Decompress(compressed)
+
+	require.NoError(t, err)
+	require.Equal(t, data, decompressed)
+
+	_, err = beanstalk.GzipCompressor.Decompress([]byte("test"))
+
+	require.Error(t, err)
+}
+
+func TestClient_Compression(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	logger := &recordingLogger{}
+
+	producer, err := beanstalk.DialWithOptions(s.Addr, &beanstalk.DialOptions{
+		Client: &beanstalk.ClientOptions{Logger: logger, Compressor: beanstalk.GzipCompressor, CompressThreshold: 100},
+	})
+
+	require.NoError(t, err)
+
+	defer producer.Close()
+
+	consumer, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer consumer.Close()
+
+	large := []byte(strings.Repeat(`{"to":"a@example.com"}`, 100))
+
+	// payloads up to the threshold are put as is
+	_, err = producer.Put(1, 0, 1*time.Minute, []byte("small"))
+
+	require.Nil(t, err)
+
+	job, err := consumer.Reserve()
+
+	require.Nil(t, err)
+	require.Equal(t, []byte("small"), job.Data)
+
+	_, err = producer.Put(1, 0, 1*time.Minute, large)
+
+	require.Nil(t, err)
+
+	job, err = consumer.Reserve()
+
+	require.Nil(t, err)
+	// the body is decompressed on reserve
+	require.Equal(t, large, job.Data)
+	require.Equal(t, "gzip", job.Headers().Get(beanstalk.HeaderContentEncoding))
+	require.Equal(t, large, job.Body())
+
+	// as is a peeked one
+	job, err = consumer.Peek(job.ID)
+
+	require.Nil(t, err)
+	require.Equal(t, large, job.Data)
+
+	require.Len(t, logger.entries, 1)
+	require.Equal(t, beanstalk.DebugLogLevel, logger.entries[0].level)
+	require.Equal(t, len(large), logger.entries[0].args["size"])
+	require.Less(t, logger.entries[0].args["compressedSize"], len(large))
+
+	// headers of enveloped payloads are kept and the value is decoded transparently
+	_, err = beanstalk.PutValue(producer, &beanstalk.PutOptions{
+		TTR:     1 * time.Minute,
+		Headers: beanstalk.Headers{beanstalk.HeaderTraceID: "abc"},
+	}, strings.Repeat("test", 100))
+
+	require.Nil(t, err)
+
+	job, err = consumer.Reserve()
+
+	require.Nil(t, err)
+	require.Equal(t, "abc", job.Headers().Get(beanstalk.HeaderTraceID))
+	require.Equal(t, "gzip", job.Headers().Get(beanstalk.HeaderContentEncoding))
+
+	var v string
+
+	require.NoError(t, job.Decode(&v))
+	require.Equal(t, strings.Repeat("test", 100), v)
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	beanstalk.RegisterCompressor(reverseCompressor{})
+
+	body, err := reverseCompressor{}.Compress([]byte("abcabc"))
+
+	require.NoError(t, err)
+
+	data, err := beanstalk.EncodeEnvelope(beanstalk.Headers{beanstalk.HeaderContentEncoding: "reverse"}, body)
+
+	require.NoError(t, err)
+	require.Equal(t, []byte("abcabc"), (&beanstalk.Job{Data: data}).Body())
+
+	data, err = beanstalk.EncodeEnvelope(beanstalk.Headers{beanstalk.HeaderContentEncoding: "unknown"}, []byte("test"))
+
+	require.NoError(t, err)
+
+	job := &beanstalk.Job{Data: data}
+
+	require.Equal(t, data, job.Body())
+
+	var v string
+
+	require.True(t, errors.Is(job.Decode(&v), beanstalk.ErrUnknownEncoding))
+}
@@ -0,0 +1,119 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+var aLongTimeAgo = time.Unix(1, 0)
+
+type deadlineConn interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// ioDeadline manages the deadline of one direction of the connection while a
+// request is written or a response is read. It applies the deadline of the
+// context and the timeouts of the client, and interrupts blocked I/O once the
+// context is cancelled.
+type ioDeadline struct {
+	ctx         context.Context
+	set         func(time.Time) error
+	mutex       sync.Mutex
+	interrupted bool
+	cancel      func() bool
+	doneCh      chan struct{}
+}
+
+func (c *Client) startDeadline(ctx context.Context, set func(time.Time) error) *ioDeadline {
+	d := &ioDeadline{ctx: ctx, set: set}
+
+	if ctx.Done() != nil {
+		d.doneCh = make(chan struct{})
+
+		d.cancel = context.AfterFunc(ctx, func() {
+			defer close(d.doneCh)
+
+			d.mutex.Lock()
+			defer d.mutex.Unlock()
+
+			d.interrupted = true
+
+			_ = d.set(aLongTimeAgo)
+		})
+	}
+
+	return d
+}
+
+// reset sets the deadline to the earliest of the context deadline and the timeout
+// from now, a zero timeout means no limit.
+func (d *ioDeadline) reset(timeout time.Duration) {
+	deadline, ok := d.ctx.Deadline()
+	if !ok && timeout == 0 {
+		return
+	}
+
+	if timeout > 0 {
+		if t := time.Now().Add(timeout); !ok || t.Before(deadline) {
+			deadline = t
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.interrupted {
+		_ = d.set(deadline)
+	}
+}
+
+// stop restores the connection to the blocking mode.
+func (d *ioDeadline) stop() {
+	if d.cancel != nil && !d.cancel() {
+		<-d.doneCh
+	}
+
+	_ = d.set(time.Time{})
+}
+
+// timeoutError turns an exceeded deadline, that was caused by the timeout
+// rather than the context, into a TimeoutError.
+func (d *ioDeadline) timeoutError(err error, op string, timeout time.Duration) error {
+	if timeout == 0 || !errors.Is(err, os.ErrDeadlineExceeded) || d.ctx.Err() != nil {
+		return err
+	}
+
+	if deadline, ok := d.ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return err
+	}
+
+	return &TimeoutError{Op: op, Duration: timeout}
+}
+
+func (c *Client) setReadDeadline(t time.Time) error {
+	if conn, ok := c.rwc.(deadlineConn); ok {
+		return conn.SetReadDeadline(t)
+	}
+
+	if t.Equal(aLongTimeAgo) {
+		return c.rwc.Close()
+	}
+
+	return nil
+}
+
+func (c *Client) setWriteDeadline(t time.Time) error {
+	if conn, ok := c.rwc.(deadlineConn); ok {
+		return conn.SetWriteDeadline(t)
+	}
+
+	if t.Equal(aLongTimeAgo) {
+		return c.rwc.Close()
+	}
+
+	return nil
+}
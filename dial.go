@@ -0,0 +1,95 @@
+package beanstalk
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+type DialOptions struct {
+	// is "tcp" by default, "tcp4", "tcp6" and "unix" are also supported
+	Network string
+	// limits the time to establish the connection, including the TLS handshake
+	Timeout time.Duration
+	// is the TCP keep-alive period, zero enables the default period and negative disables keep-alives
+	KeepAlive time.Duration
+	// enables TLS, e.g. for a beanstalkd behind stunnel
+	TLSConfig *tls.Config
+	// replaces the default dialer, KeepAlive is ignored then
+	Dialer *net.Dialer
+	// replaces Dialer to establish the underlying connection, e.g. through a proxy
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	Client      *ClientOptions
+}
+
+func DialWithOptions(address string, options *DialOptions) (*Client, error) {
+	return DialContext(context.Background(), address, options)
+}
+
+func DialContext(ctx context.Context, address string, options *DialOptions) (*Client, error) {
+	if options == nil {
+		options = &DialOptions{}
+	}
+
+	network := options.Network
+	if network == "" {
+		network = "tcp"
+	}
+
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+
+		defer cancel()
+	}
+
+	dialContext := options.DialContext
+	if dialContext == nil {
+		dialer := options.Dialer
+		if dialer == nil {
+			dialer = &net.Dialer{KeepAlive: options.KeepAlive}
+		}
+
+		dialContext = dialer.DialContext
+	}
+
+	conn, err := dialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.TLSConfig != nil {
+		config := options.TLSConfig
+		if config.ServerName == "" && network != "unix" {
+			if host, _, err := net.SplitHostPort(address); err == nil {
+				config = config.Clone()
+				config.ServerName = host
+			}
+		}
+
+		tlsConn := tls.Client(conn, config)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+
+			return nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	clientOptions := options.Client
+	if clientOptions == nil {
+		clientOptions = &ClientOptions{}
+	}
+
+	return NewClientWithOptions(conn, clientOptions), nil
+}
+
+// NewDialer returns a factory suitable for PoolOptions.Dialer and WorkerOptions.Dialer.
+func NewDialer(address string, options *DialOptions) func() (*Client, error) {
+	return func() (*Client, error) {
+		return DialWithOptions(address, options)
+	}
+}
@@ -0,0 +1,224 @@
+package beanstalk_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/stretchr/testify/require"
+)
+
+// serveUsing answers every command of the accepted connections with "USING default".
+func serveUsing(t *testing.T, listener net.Listener) {
+	t.Helper()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				r := bufio.NewReader(conn)
+
+				for {
+					if _, err := r.ReadString('\n'); err != nil {
+						return
+					}
+
+					if _, err := conn.Write([]byte("USING default\r\n")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+}
+
+func TestDial(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	defer listener.Close()
+
+	serveUsing(t, listener)
+
+	c, err := beanstalk.Dial(listener.Addr().String())
+
+	require.Nil(t, err)
+
+	tube, err := c.ListTubeUsed()
+
+	require.Nil(t, err)
+	require.Equal(t, "default", tube)
+
+	require.NoError(t, c.Close())
+}
+
+func TestDialWithOptions(t *testing.T) {
+	t.Run("unix", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("unix sockets are not supported")
+		}
+
+		address := filepath.Join(t.TempDir(), "beanstalkd.sock")
+
+		listener, err := net.Listen("unix", address)
+
+		require.NoError(t, err)
+
+		defer listener.Close()
+
+		serveUsing(t, listener)
+
+		c, err := beanstalk.DialWithOptions(address, &beanstalk.DialOptions{Network: "unix", Timeout: 1 * time.Second})
+
+		require.Nil(t, err)
+
+		tube, err := c.ListTubeUsed()
+
+		require.Nil(t, err)
+		require.Equal(t, "default", tube)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{generateCertificate(t)}})
+
+		require.NoError(t, err)
+
+		defer listener.Close()
+
+		serveUsing(t, listener)
+
+		c, err := beanstalk.DialWithOptions(listener.Addr().String(), &beanstalk.DialOptions{
+			Timeout:   1 * time.Second,
+			TLSConfig: &tls.Config{InsecureSkipVerify: true},
+		})
+
+		require.Nil(t, err)
+
+		tube, err := c.ListTubeUsed()
+
+		require.Nil(t, err)
+		require.Equal(t, "default", tube)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("dial context", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			r := bufio.NewReader(serverConn)
+
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+
+			_, _ = serverConn.Write([]byte("USING test\r\n"))
+		}()
+
+		var dialed string
+
+		c, err := beanstalk.DialWithOptions("beanstalkd:11300", &beanstalk.DialOptions{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialed = network + "://" + address
+
+				return clientConn, nil
+			},
+			Client: &beanstalk.ClientOptions{Concurrent: true},
+		})
+
+		require.Nil(t, err)
+		require.Equal(t, "tcp://beanstalkd:11300", dialed)
+
+		tube, err := c.Use("test")
+
+		require.Nil(t, err)
+		require.Equal(t, "test", tube)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		expected := errors.New("test")
+
+		c, err := beanstalk.DialWithOptions("beanstalkd:11300", &beanstalk.DialOptions{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return nil, expected
+			},
+		})
+
+		require.Equal(t, expected, err)
+		require.Nil(t, c)
+	})
+}
+
+func TestNewDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	require.NoError(t, err)
+
+	defer listener.Close()
+
+	serveUsing(t, listener)
+
+	options := &beanstalk.ClientOptions{}
+
+	pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{
+		Dialer:   beanstalk.NewDialer(listener.Addr().String(), &beanstalk.DialOptions{Timeout: 1 * time.Second, Client: options}),
+		Capacity: 4,
+	})
+
+	require.NoError(t, pool.Open(context.Background()))
+
+	require.Equal(t, 4, pool.Len())
+
+	// the clients dialed concurrently apply their defaults to copies
+	require.Equal(t, &beanstalk.ClientOptions{}, options)
+
+	require.NoError(t, pool.Close(context.Background()))
+}
+
+func generateCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	require.NoError(t, err)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
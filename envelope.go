@@ -0,0 +1,190 @@
+package beanstalk
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderContentType = "Content-Type"
+	HeaderTraceID     = "Trace-Id"
+	HeaderProducer    = "Producer"
+	// is set by PutValue in RFC 3339 format unless given
+	HeaderEnqueuedAt = "Enqueued-At"
+)
+
+// An envelope starts with the magic bytes and the format version, followed by the
+// uvarint length of the JSON encoded headers, the headers and the body.
+const (
+	envelopeMagic   = "\x00bsk"
+	envelopeVersion = 1
+)
+
+var ErrMalformedEnvelope = errors.New("beanstalk: envelope: malformed")
+
+type Headers map[string]string
+
+func (h Headers) Get(key string) string {
+	return h[key]
+}
+
+func (h Headers) Set(key, value string) {
+	h[key] = value
+}
+
+// ContentTyper is implemented by codecs that name the content type of their payloads,
+// PutValue records it in the Content-Type header.
+type ContentTyper interface {
+	ContentType() string
+}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (gobCodec) ContentType() string {
+	return "application/x-gob"
+}
+
+func (binaryCodec) ContentType() string {
+	return "application/octet-stream"
+}
+
+// EncodeEnvelope wraps the body with the headers.
+func EncodeEnvelope(headers Headers, body []byte) ([]byte, error) {
+	header, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(envelopeMagic)+1+binary.MaxVarintLen64+len(header)+len(body))
+	data = append(data, envelopeMagic...)
+	data = append(data, envelopeVersion)
+	data = binary.AppendUvarint(data, uint64(len(header)))
+	data = append(data, header...)
+	data = append(data, body...)
+
+	return data, nil
+}
+
+// DecodeEnvelope splits an envelope into its headers and body. Plain payloads are
+// returned as the body without headers, so jobs of older producers stay readable.
+func DecodeEnvelope(data []byte) (Headers, []byte, error) {
+	if !IsEnvelope(data) {
+		return nil, data, nil
+	}
+
+	data = data[len(envelopeMagic):]
+
+	if len(data) == 0 || data[0] != envelopeVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported version", ErrMalformedEnvelope)
+	}
+
+	size, n := binary.Uvarint(data[1:])
+	if n <= 0 || uint64(len(data)-1-n) < size {
+		return nil, nil, fmt.Errorf("%w: truncated headers", ErrMalformedEnvelope)
+	}
+
+	data = data[1+n:]
+
+	var headers Headers
+	if err := json.Unmarshal(data[:size], &headers); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrMalformedEnvelope, err)
+	}
+
+	return headers, data[size:], nil
+}
+
+func IsEnvelope(data []byte) bool {
+	return strings.HasPrefix(string(data), envelopeMagic)
+}
+
+// newJob opens the envelope of a reserved or peeked payload, so Data holds the decompressed
+// body and the headers are kept apart. Plain payloads and envelopes that cannot be opened,
+// e.g. of an unknown encoding, are kept as is.
+func newJob(id int, data []byte) *Job {
+	headers, body, err := openEnvelope(data)
+	if err != nil || headers == nil {
+		return &Job{ID: id, Data: data}
+	}
+
+	return &Job{ID: id, Data: body, headers: headers}
+}
+
+// Headers returns the headers of an enveloped job, or nil for a plain or malformed payload.
+func (j *Job) Headers() Headers {
+	if j.headers != nil {
+		return j.headers
+	}
+
+	headers, _, err := DecodeEnvelope(j.Data)
+	if err != nil {
+		return nil
+	}
+
+	return headers
+}
+
+// Body returns the payload without the envelope, decompressed if needed. It equals Data
+// for reserved and peeked jobs, and opens the envelope of a Job built from raw data. Plain
+// and malformed payloads are returned as is.
+func (j *Job) Body() []byte {
+	if j.headers != nil {
+		return j.Data
+	}
+
+	_, body, err := openEnvelope(j.Data)
+	if err != nil {
+		return j.Data
+	}
+
+	return body
+}
+
+// envelope returns the payload to put the job again with its headers, the body is
+// compressed again by the putting client if it is configured to.
+func (j *Job) envelope() []byte {
+	if j.headers == nil {
+		return j.Data
+	}
+
+	headers := make(Headers, len(j.headers))
+	for key, value := range j.headers {
+		if key != HeaderContentEncoding {
+			headers[key] = value
+		}
+	}
+
+	data, err := EncodeEnvelope(headers, j.Data)
+	if err != nil {
+		// headers of strings always encode
+		return j.Data
+	}
+
+	return data
+}
+
+// seal wraps the payload with the headers, adding the content type of the codec and
+// the enqueue time unless they are given.
+func seal(headers Headers, codec Codec, data []byte) ([]byte, error) {
+	sealed := make(Headers, len(headers)+2)
+	for key, value := range headers {
+		sealed[key] = value
+	}
+
+	if _, ok := sealed[HeaderContentType]; !ok {
+		if typer, ok := codec.(ContentTyper); ok {
+			sealed[HeaderContentType] = typer.ContentType()
+		}
+	}
+
+	if _, ok := sealed[HeaderEnqueuedAt]; !ok {
+		sealed[HeaderEnqueuedAt] = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	return EncodeEnvelope(sealed, data)
+}
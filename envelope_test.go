@@ -0,0 +1,119 @@
+package beanstalk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		data, err := beanstalk.EncodeEnvelope(beanstalk.Headers{beanstalk.HeaderTraceID: "abc"}, []byte("test"))
+
+		require.NoError(t, err)
+		require.True(t, beanstalk.IsEnvelope(data))
+
+		headers, body, err := beanstalk.DecodeEnvelope(data)
+
+		require.NoError(t, err)
+		require.Equal(t, beanstalk.Headers{beanstalk.HeaderTraceID: "abc"}, headers)
+		require.Equal(t, []byte("test"), body)
+
+		job := &beanstalk.Job{ID: 1, Data: data}
+
+		require.Equal(t, "abc", job.Headers().Get(beanstalk.HeaderTraceID))
+		require.Equal(t, []byte("test"), job.Body())
+	})
+
+	t.Run("plain payload", func(t *testing.T) {
+		headers, body, err := beanstalk.DecodeEnvelope([]byte(`{"to":"a@example.com"}`))
+
+		require.NoError(t, err)
+		require.Nil(t, headers)
+		require.Equal(t, []byte(`{"to":"a@example.com"}`), body)
+
+		job := &beanstalk.Job{ID: 1, Data: []byte("test")}
+
+		require.Nil(t, job.Headers())
+		require.Equal(t, "", job.Headers().Get(beanstalk.HeaderTraceID))
+		require.Equal(t, []byte("test"), job.Body())
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, data := range [][]byte{
+			[]byte("\x00bsk"),
+			[]byte("\x00bsk\x02"),
+			[]byte("\x00bsk\x01\x10{}"),
+			[]byte("\x00bsk\x01\x02[]"),
+		} {
+			_, _, err := beanstalk.DecodeEnvelope(data)
+
+			require.True(t, errors.Is(err, beanstalk.ErrMalformedEnvelope), "%q", data)
+
+			job := &beanstalk.Job{Data: data}
+
+			require.Nil(t, job.Headers())
+			require.Equal(t, data, job.Body())
+		}
+	})
+}
+
+func TestPutValue_Headers(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	_, err = beanstalk.PutValue(c, &beanstalk.PutOptions{
+		TTR:     1 * time.Minute,
+		Codec:   beanstalk.GobCodec,
+		Headers: beanstalk.Headers{beanstalk.HeaderTraceID: "abc", beanstalk.HeaderProducer: "test"},
+	}, email{To: "a@example.com"})
+
+	require.Nil(t, err)
+
+	job, err := c.Reserve()
+
+	require.Nil(t, err)
+	// the envelope is opened on reserve
+	require.False(t, beanstalk.IsEnvelope(job.Data))
+	require.Equal(t, job.Body(), job.Data)
+
+	headers := job.Headers()
+
+	require.Equal(t, "abc", headers.Get(beanstalk.HeaderTraceID))
+	require.Equal(t, "test", headers.Get(beanstalk.HeaderProducer))
+	require.Equal(t, "application/x-gob", headers.Get(beanstalk.HeaderContentType))
+
+	enqueuedAt, err := time.Parse(time.RFC3339Nano, headers.Get(beanstalk.HeaderEnqueuedAt))
+
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), enqueuedAt, 1*time.Minute)
+
+	// the codec is resolved from the content type
+	var v email
+
+	require.NoError(t, job.Decode(&v))
+	require.Equal(t, email{To: "a@example.com"}, v)
+
+	var received email
+
+	handler := beanstalk.TypedHandler(nil, func(ctx context.Context, v email) error {
+		received = v
+
+		return nil
+	})
+
+	require.NoError(t, handler.ServeJob(context.Background(), job))
+	require.Equal(t, email{To: "a@example.com"}, received)
+}
@@ -1,6 +1,11 @@
 package beanstalk
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
 
 var (
 	ErrBadFormat          = errors.New("beanstalk: bad format")
@@ -17,4 +22,28 @@ var (
 	ErrUnknownCommand     = errors.New("beanstalk: unknown command")
 	ErrMalformedCommand   = errors.New("beanstalk: malformed command")
 	ErrUnexpectedResponse = errors.New("beanstalk: unexpected response")
+	ErrBlockingCommand    = errors.New("beanstalk: blocking command would starve concurrent callers")
 )
+
+// TimeoutError is returned when a request or a response exceeds the write or read
+// timeout of the client, the client is closed then.
+type TimeoutError struct {
+	Op       string
+	Duration time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("beanstalk: %s timeout after %s", e.Op, e.Duration)
+}
+
+func (e *TimeoutError) Timeout() bool {
+	return true
+}
+
+func (e *TimeoutError) Temporary() bool {
+	return false
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return os.ErrDeadlineExceeded
+}
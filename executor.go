@@ -0,0 +1,236 @@
+package beanstalk
+
+import (
+	"context"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// executor is implemented by clients, the typed commands below are shared by them.
+type executor interface {
+	ExecuteCommandContext(ctx context.Context, command Command) (CommandResponse, error)
+}
+
+func executePut(ctx context.Context, e executor, priority uint32, delay, ttr time.Duration, data []byte) (int, error) {
+	r, err := e.ExecuteCommandContext(ctx, PutCommand{Priority: priority, Delay: delay, TTR: ttr, Data: data})
+	if err != nil {
+		return 0, err
+	}
+
+	return r.(PutCommandResponse).ID, nil
+}
+
+func executeUse(ctx context.Context, e executor, tube string) (string, error) {
+	r, err := e.ExecuteCommandContext(ctx, UseCommand{Tube: tube})
+	if err != nil {
+		return "", err
+	}
+
+	return r.(UseCommandResponse).Tube, nil
+}
+
+func executeReserve(ctx context.Context, e executor) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, ReserveCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(ReserveCommandResponse).ID, r.(ReserveCommandResponse).Data), nil
+}
+
+func executeReserveWithTimeout(ctx context.Context, e executor, timeout time.Duration) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, ReserveWithTimeoutCommand{Timeout: timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(ReserveWithTimeoutCommandResponse).ID, r.(ReserveWithTimeoutCommandResponse).Data), nil
+}
+
+func executeReserveJob(ctx context.Context, e executor, id int) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, ReserveJobCommand{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(ReserveJobCommandResponse).ID, r.(ReserveJobCommandResponse).Data), nil
+}
+
+func executeDelete(ctx context.Context, e executor, id int) error {
+	_, err := e.ExecuteCommandContext(ctx, DeleteCommand{ID: id})
+
+	return err
+}
+
+func executeRelease(ctx context.Context, e executor, id int, priority uint32, delay time.Duration) error {
+	_, err := e.ExecuteCommandContext(ctx, ReleaseCommand{ID: id, Priority: priority, Delay: delay})
+
+	return err
+}
+
+func executeBury(ctx context.Context, e executor, id int, priority uint32) error {
+	_, err := e.ExecuteCommandContext(ctx, BuryCommand{ID: id, Priority: priority})
+
+	return err
+}
+
+func executeTouch(ctx context.Context, e executor, id int) error {
+	_, err := e.ExecuteCommandContext(ctx, TouchCommand{ID: id})
+
+	return err
+}
+
+func executeWatch(ctx context.Context, e executor, tube string) (int, error) {
+	r, err := e.ExecuteCommandContext(ctx, WatchCommand{Tube: tube})
+	if err != nil {
+		return 0, err
+	}
+
+	return r.(WatchCommandResponse).Count, nil
+}
+
+func executeIgnore(ctx context.Context, e executor, tube string) (int, error) {
+	r, err := e.ExecuteCommandContext(ctx, IgnoreCommand{Tube: tube})
+	if err != nil {
+		return 0, err
+	}
+
+	return r.(IgnoreCommandResponse).Count, nil
+}
+
+func executePeek(ctx context.Context, e executor, id int) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, PeekCommand{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(PeekCommandResponse).ID, r.(PeekCommandResponse).Data), nil
+}
+
+func executePeekReady(ctx context.Context, e executor) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, PeekReadyCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(PeekReadyCommandResponse).ID, r.(PeekReadyCommandResponse).Data), nil
+}
+
+func executePeekDelayed(ctx context.Context, e executor) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, PeekDelayedCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(PeekDelayedCommandResponse).ID, r.(PeekDelayedCommandResponse).Data), nil
+}
+
+func executePeekBuried(ctx context.Context, e executor) (*Job, error) {
+	r, err := e.ExecuteCommandContext(ctx, PeekBuriedCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newJob(r.(PeekBuriedCommandResponse).ID, r.(PeekBuriedCommandResponse).Data), nil
+}
+
+func executeKick(ctx context.Context, e executor, bound int) (int, error) {
+	r, err := e.ExecuteCommandContext(ctx, KickCommand{Bound: bound})
+	if err != nil {
+		return 0, err
+	}
+
+	return r.(KickCommandResponse).Count, nil
+}
+
+func executeKickJob(ctx context.Context, e executor, id int) error {
+	_, err := e.ExecuteCommandContext(ctx, KickJobCommand{ID: id})
+
+	return err
+}
+
+func executeStatsJob(ctx context.Context, e executor, id int) (*StatsJob, error) {
+	r, err := e.ExecuteCommandContext(ctx, StatsJobCommand{ID: id})
+	if err != nil {
+		return nil, err
+	}
+
+	var stats StatsJob
+	if err = yaml.Unmarshal(r.(StatsJobCommandResponse).Data, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, err
+}
+
+func executeStatsTube(ctx context.Context, e executor, tube string) (*StatsTube, error) {
+	r, err := e.ExecuteCommandContext(ctx, StatsTubeCommand{Tube: tube})
+	if err != nil {
+		return nil, err
+	}
+
+	var stats StatsTube
+	if err = yaml.Unmarshal(r.(StatsTubeCommandResponse).Data, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, err
+}
+
+func executeStats(ctx context.Context, e executor) (*Stats, error) {
+	r, err := e.ExecuteCommandContext(ctx, StatsCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	var stats Stats
+	if err = yaml.Unmarshal(r.(StatsCommandResponse).Data, &stats); err != nil {
+		return nil, err
+	}
+
+	return &stats, err
+}
+
+func executeListTubes(ctx context.Context, e executor) ([]string, error) {
+	r, err := e.ExecuteCommandContext(ctx, ListTubesCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	var tubes []string
+	if err = yaml.Unmarshal(r.(ListTubesCommandResponse).Data, &tubes); err != nil {
+		return nil, err
+	}
+
+	return tubes, nil
+}
+
+func executeListTubeUsed(ctx context.Context, e executor) (string, error) {
+	r, err := e.ExecuteCommandContext(ctx, ListTubeUsedCommand{})
+	if err != nil {
+		return "", err
+	}
+
+	return r.(ListTubeUsedCommandResponse).Tube, nil
+}
+
+func executeListTubesWatched(ctx context.Context, e executor) ([]string, error) {
+	r, err := e.ExecuteCommandContext(ctx, ListTubesWatchedCommand{})
+	if err != nil {
+		return nil, err
+	}
+
+	var tubes []string
+	if err = yaml.Unmarshal(r.(ListTubesWatchedCommandResponse).Data, &tubes); err != nil {
+		return nil, err
+	}
+
+	return tubes, nil
+}
+
+func executePauseTube(ctx context.Context, e executor, tube string, delay time.Duration) error {
+	_, err := e.ExecuteCommandContext(ctx, PauseTubeCommand{Tube: tube, Delay: delay})
+
+	return err
+}
@@ -0,0 +1,383 @@
+package beanstalk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	ReadyState   = "ready"
+	DelayedState = "delayed"
+	BuriedState  = "buried"
+)
+
+// ExportedJob is a line of an export, see ExportTube.
+type ExportedJob struct {
+	ID   int    `json:"id"`
+	Tube string `json:"tube"`
+	// is "ready", "delayed" or "buried"
+	State    string `json:"state"`
+	Priority uint32 `json:"priority"`
+	// is the number of seconds left until a delayed job is ready
+	Delay int `json:"delay"`
+	// is the number of seconds a worker is allowed to run the job
+	TTR int `json:"ttr"`
+	// is base64 encoded
+	Data []byte `json:"data"`
+}
+
+type ExportOptions struct {
+	// deletes the exported jobs instead of restoring them, e.g. when moving them to another server
+	Delete bool
+}
+
+type ImportOptions struct {
+	// puts all jobs into this tube instead of the tube they were exported from
+	Tube string
+}
+
+// ExportTube writes the ready, delayed and buried jobs of the tube to w as JSON Lines of
+// ExportedJob. The jobs are peeked one by one and stepped past by reserving them by id, see
+// scanJobs, so their reserves, releases and buries statistics grow, and skipped ready jobs
+// are invisible to workers until the export ends. The used tube of the client is restored.
+func ExportTube(ctx context.Context, c *Client, tube string, w io.Writer, options *ExportOptions) (int, error) {
+	if options == nil {
+		options = &ExportOptions{}
+	}
+
+	enc := json.NewEncoder(w)
+
+	count := 0
+
+	err := scanJobs(ctx, c, tube, []string{ReadyState, DelayedState, BuriedState}, func(s *scannedJob) (bool, error) {
+		if err := enc.Encode(s.export()); err != nil {
+			return false, err
+		}
+
+		count++
+
+		if !options.Delete {
+			return false, nil
+		}
+
+		if err := deleteJob(ctx, c, s.job.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return false, err
+		}
+
+		return true, nil
+	})
+
+	return count, err
+}
+
+// ImportJobs puts the jobs of an export, preserving their priority, TTR and delay left.
+// Buried jobs are buried again after the put. The used tube of the client is restored.
+func ImportJobs(ctx context.Context, c *Client, r io.Reader, options *ImportOptions) (int, error) {
+	if options == nil {
+		options = &ImportOptions{}
+	}
+
+	used, err := c.ListTubeUsedContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	current := used
+
+	defer func() {
+		if current != used {
+			_, _ = c.UseContext(context.WithoutCancel(ctx), used)
+		}
+	}()
+
+	dec := json.NewDecoder(r)
+
+	count := 0
+
+	for {
+		var job ExportedJob
+		if err := dec.Decode(&job); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, fmt.Errorf("beanstalk: import: job %d: %w", count+1, err)
+		}
+
+		tube := options.Tube
+		if tube == "" {
+			tube = job.Tube
+		}
+
+		if tube == "" {
+			tube = "default"
+		}
+
+		if tube != current {
+			if _, err := c.UseContext(ctx, tube); err != nil {
+				return count, err
+			}
+
+			current = tube
+		}
+
+		if _, err := putExported(ctx, c, &job); err != nil {
+			return count, fmt.Errorf("beanstalk: import: job %d: %w", count+1, err)
+		}
+
+		count++
+	}
+}
+
+// putExported puts the job into the used tube in its exported state. Buried jobs are put
+// parked, so no worker can reserve them before they are buried.
+func putExported(ctx context.Context, c *Client, job *ExportedJob) (int, error) {
+	if job.State != ReadyState && job.State != DelayedState && job.State != BuriedState {
+		return 0, fmt.Errorf("unknown state %q", job.State)
+	}
+
+	var delay time.Duration
+
+	switch job.State {
+	case DelayedState:
+		delay = time.Duration(job.Delay) * time.Second
+
+	case BuriedState:
+		delay = parkDelay
+	}
+
+	id, err := c.PutContext(ctx, job.Priority, delay, time.Duration(job.TTR)*time.Second, job.Data)
+	if err != nil || job.State != BuriedState {
+		return id, err
+	}
+
+	if _, err = c.ReserveJobContext(ctx, id); err == nil {
+		err = c.BuryContext(ctx, id, job.Priority)
+	}
+
+	if err != nil {
+		// the parked job would never become ready
+		_ = c.DeleteContext(context.WithoutCancel(ctx), id)
+
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// deleteJob deletes a peeked job, reserving it first, so its blob is deleted as well.
+func deleteJob(ctx context.Context, c *Client, id int) error {
+	var blobErr *BlobError
+
+	if _, err := c.ReserveJobContext(ctx, id); err != nil && !errors.As(err, &blobErr) {
+		return err
+	}
+
+	return c.DeleteContext(ctx, id)
+}
+
+// parkDelay is the longest delay the server accepts, it keeps jobs put by putExported from
+// workers until they are buried.
+const parkDelay = math.MaxUint32 * time.Second
+
+// is returned by the visit function of scanJobs to end the scan without an error
+var errStopScan = errors.New("beanstalk: stop scan")
+
+// scannedJob is a job peeked by scanJobs with its statistics.
+type scannedJob struct {
+	job   *Job
+	state string
+	stats *StatsJob
+	// is when the statistics were taken, to tell the delay left
+	at time.Time
+}
+
+func (s *scannedJob) export() *ExportedJob {
+	e := &ExportedJob{
+		ID:       s.job.ID,
+		Tube:     s.stats.Tube,
+		State:    s.state,
+		Priority: uint32(s.stats.Priority),
+		TTR:      s.stats.TTR,
+		Data:     s.job.envelope(),
+	}
+
+	if s.state == DelayedState {
+		e.Delay = s.stats.TimeLeft
+	}
+
+	return e
+}
+
+// restore returns the job, reserved by id, to its state with its priority and delay left.
+func (s *scannedJob) restore(ctx context.Context, c *Client) error {
+	priority := uint32(s.stats.Priority)
+
+	switch s.state {
+	case BuriedState:
+		return c.BuryContext(ctx, s.job.ID, priority)
+
+	case DelayedState:
+		left := time.Duration(s.stats.TimeLeft)*time.Second - time.Since(s.at)
+		if left < 0 {
+			left = 0
+		}
+
+		return c.ReleaseContext(ctx, s.job.ID, priority, left)
+
+	default:
+		return c.ReleaseContext(ctx, s.job.ID, priority, 0)
+	}
+}
+
+// scanJobs visits the jobs of the tube in the given states one by one by peeking the head of
+// each state. A job is visited before it is reserved, and visit reports whether it took the
+// job out of its state, e.g. by deleting or kicking it. Other jobs are reserved by id to get
+// past them:
+//
+//   - buried jobs are buried again at once, which moves them to the back of the queue, so
+//     the scan of buried jobs ends when a visited job comes around again
+//   - ready and delayed jobs are held until the scan ends, then released with their delay
+//     left, as a released job would be peeked again; a job whose reservation expires
+//     meanwhile is ready and held again when peeked, and a lost connection hands the jobs
+//     back to the server
+//
+// Returning errStopScan from visit ends the scan without an error.
+func scanJobs(ctx context.Context, c *Client, tube string, states []string, visit func(s *scannedJob) (bool, error)) (err error) {
+	used, err := c.ListTubeUsedContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	if used != tube {
+		if _, err := c.UseContext(ctx, tube); err != nil {
+			return err
+		}
+
+		defer func() {
+			_, _ = c.UseContext(context.WithoutCancel(ctx), used)
+		}()
+	}
+
+	var aside []*scannedJob
+
+	defer func() {
+		if restoreErr := restoreAside(ctx, c, aside); err == nil {
+			err = restoreErr
+		}
+	}()
+
+	peeks := map[string]func(context.Context) (*Job, error){
+		ReadyState:   c.PeekReadyContext,
+		DelayedState: c.PeekDelayedContext,
+		BuriedState:  c.PeekBuriedContext,
+	}
+
+	seen := map[int]bool{}
+
+	for _, state := range states {
+		peek := peeks[state]
+
+		for {
+			head, err := peek(ctx)
+			if errors.Is(err, ErrNotFound) {
+				break
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if seen[head.ID] && state == BuriedState {
+				break
+			}
+
+			if seen[head.ID] {
+				// the reservation expired, so the job is held again
+				if _, err := c.ReserveJobContext(ctx, head.ID); err != nil && !errors.Is(err, ErrNotFound) {
+					return err
+				}
+
+				continue
+			}
+
+			stats, err := c.StatsJobContext(ctx, head.ID)
+			if errors.Is(err, ErrNotFound) {
+				// deleted since the peek
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if stats.State != state {
+				// reserved or kicked since the peek
+				continue
+			}
+
+			seen[head.ID] = true
+
+			s := &scannedJob{job: head, state: state, stats: stats, at: time.Now()}
+
+			taken, err := visit(s)
+			if err == errStopScan {
+				return nil
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if taken {
+				continue
+			}
+
+			if _, err := c.ReserveJobContext(ctx, head.ID); errors.Is(err, ErrNotFound) {
+				// reserved by a worker or deleted since the peek
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			if state != BuriedState {
+				aside = append(aside, s)
+
+				continue
+			}
+
+			if err := s.restore(ctx, c); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// restoreAside releases the jobs set aside by scanJobs with their priority and delay left.
+// Jobs whose reservation expired meanwhile are ready, delayed ones are reserved again to
+// restore their delay.
+func restoreAside(ctx context.Context, c *Client, aside []*scannedJob) error {
+	ctx = context.WithoutCancel(ctx)
+
+	var firstErr error
+
+	for _, s := range aside {
+		err := s.restore(ctx, c)
+		if errors.Is(err, ErrNotFound) && s.state == DelayedState {
+			if _, err = c.ReserveJobContext(ctx, s.job.ID); err == nil {
+				err = s.restore(ctx, c)
+			}
+		}
+
+		if err != nil && !errors.Is(err, ErrNotFound) && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,292 @@
+package beanstalk_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+// fillTube puts a ready, a delayed and a buried job into the tube.
+func fillTube(t *testing.T, c *beanstalk.Client, tube string) {
+	t.Helper()
+
+	_, err := c.Use(tube)
+
+	require.NoError(t, err)
+
+	_, err = c.Put(5, 0, 30*time.Second, []byte("ready"))
+
+	require.NoError(t, err)
+
+	_, err = c.Put(6, 1*time.Minute, 30*time.Second, []byte("delayed"))
+
+	require.NoError(t, err)
+
+	id, err := c.Put(7, 0, 30*time.Second, []byte("buried"))
+
+	require.NoError(t, err)
+
+	_, err = c.ReserveJob(id)
+
+	require.NoError(t, err)
+	require.NoError(t, c.Bury(id, 7))
+
+	_, err = c.Use("default")
+
+	require.NoError(t, err)
+}
+
+func TestExportTube(t *testing.T) {
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: beanstalktest.NewFakeClock(time.Now())})
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	fillTube(t, c, "emails")
+
+	ctx := context.Background()
+
+	var b bytes.Buffer
+
+	count, err := beanstalk.ExportTube(ctx, c, "emails", &b, nil)
+
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	var jobs []beanstalk.ExportedJob
+
+	dec := json.NewDecoder(bytes.NewReader(b.Bytes()))
+	for dec.More() {
+		var job beanstalk.ExportedJob
+
+		require.NoError(t, dec.Decode(&job))
+
+		jobs = append(jobs, job)
+	}
+
+	require.Equal(t, []beanstalk.ExportedJob{
+		{ID: 1, Tube: "emails", State: beanstalk.ReadyState, Priority: 5, TTR: 30, Data: []byte("ready")},
+		{ID: 2, Tube: "emails", State: beanstalk.DelayedState, Priority: 6, Delay: 60, TTR: 30, Data: []byte("delayed")},
+		{ID: 3, Tube: "emails", State: beanstalk.BuriedState, Priority: 7, TTR: 30, Data: []byte("buried")},
+	}, jobs)
+	require.Contains(t, b.String(), `"data":"cmVhZHk="`)
+
+	// the jobs are restored and the used tube is kept
+	stats, err := c.StatsTube("emails")
+
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.CurrentJobsReady)
+	require.Equal(t, 1, stats.CurrentJobsDelayed)
+	require.Equal(t, 1, stats.CurrentJobsBuried)
+	require.Equal(t, 0, stats.CurrentJobsReserved)
+
+	job, err := c.StatsJob(2)
+
+	require.NoError(t, err)
+	require.Equal(t, 6, job.Priority)
+	require.Equal(t, 60, job.TimeLeft)
+
+	used, err := c.ListTubeUsed()
+
+	require.NoError(t, err)
+	require.Equal(t, "default", used)
+
+	t.Run("Import", func(t *testing.T) {
+		other := beanstalktest.NewServer()
+
+		defer other.Close()
+
+		oc, err := beanstalk.Dial(other.Addr)
+
+		require.NoError(t, err)
+
+		defer oc.Close()
+
+		count, err := beanstalk.ImportJobs(ctx, oc, bytes.NewReader(b.Bytes()), &beanstalk.ImportOptions{Tube: "restored"})
+
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		stats, err := oc.StatsTube("restored")
+
+		require.NoError(t, err)
+		require.Equal(t, 1, stats.CurrentJobsReady)
+		require.Equal(t, 1, stats.CurrentJobsDelayed)
+		require.Equal(t, 1, stats.CurrentJobsBuried)
+
+		buried, err := oc.StatsJob(3)
+
+		require.NoError(t, err)
+		require.Equal(t, beanstalk.BuriedState, buried.State)
+		require.Equal(t, 7, buried.Priority)
+		require.Equal(t, 30, buried.TTR)
+
+		// the tube of the export is used without options
+		count, err = beanstalk.ImportJobs(ctx, oc, bytes.NewReader(b.Bytes()), nil)
+
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		stats, err = oc.StatsTube("emails")
+
+		require.NoError(t, err)
+		require.Equal(t, 3, stats.TotalJobs)
+
+		used, err := oc.ListTubeUsed()
+
+		require.NoError(t, err)
+		require.Equal(t, "default", used)
+
+		_, err = beanstalk.ImportJobs(ctx, oc, strings.NewReader(`{"state":"reserved"}`), nil)
+
+		require.EqualError(t, err, `beanstalk: import: job 1: unknown state "reserved"`)
+
+		_, err = beanstalk.ImportJobs(ctx, oc, strings.NewReader(`{"state":"ready"}`+"\n{"), nil)
+
+		require.ErrorContains(t, err, "beanstalk: import: job 2:")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		var b bytes.Buffer
+
+		count, err := beanstalk.ExportTube(ctx, c, "emails", &b, &beanstalk.ExportOptions{Delete: true})
+
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		// the empty tube is removed
+		_, err = c.StatsTube("emails")
+
+		require.ErrorIs(t, err, beanstalk.ErrNotFound)
+	})
+}
+
+// clockWriter advances the clock on each write, so reservations expire meanwhile.
+type clockWriter struct {
+	bytes.Buffer
+	clock *beanstalktest.FakeClock
+}
+
+func (w *clockWriter) Write(p []byte) (int, error) {
+	w.clock.Advance(2 * time.Second)
+
+	return w.Buffer.Write(p)
+}
+
+func TestExportTube_Expiry(t *testing.T) {
+	clock := beanstalktest.NewFakeClock(time.Now())
+
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: clock})
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	_, err = c.Put(5, 0, 1*time.Second, []byte("ready"))
+
+	require.NoError(t, err)
+
+	_, err = c.Put(6, 1*time.Minute, 1*time.Second, []byte("delayed"))
+
+	require.NoError(t, err)
+
+	for _, data := range []string{"buried 1", "buried 2"} {
+		id, err := c.Put(7, 0, 1*time.Second, []byte(data))
+
+		require.NoError(t, err)
+
+		_, err = c.ReserveJob(id)
+
+		require.NoError(t, err)
+		require.NoError(t, c.Bury(id, 7))
+	}
+
+	// the TTR of the jobs runs out while each job is written
+	count, err := beanstalk.ExportTube(context.Background(), c, "default", &clockWriter{clock: clock}, nil)
+
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+
+	stats, err := c.StatsTube("default")
+
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.CurrentJobsReady)
+	require.Equal(t, 1, stats.CurrentJobsDelayed)
+	require.Equal(t, 2, stats.CurrentJobsBuried)
+	require.Equal(t, 0, stats.CurrentJobsReserved)
+
+	// the buried jobs keep their order
+	job, err := c.PeekBuried()
+
+	require.NoError(t, err)
+	require.Equal(t, 3, job.ID)
+}
+
+// closingWriter closes the client on the given write, as a cancelled export would.
+type closingWriter struct {
+	bytes.Buffer
+	client *beanstalk.Client
+	writes int
+	close  int
+}
+
+func (w *closingWriter) Write(p []byte) (int, error) {
+	if w.writes++; w.writes == w.close {
+		_ = w.client.Close()
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func TestExportTube_Closed(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	fillTube(t, c, "emails")
+
+	exporter, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	// the ready and delayed jobs are held when the buried job is written
+	_, err = beanstalk.ExportTube(context.Background(), exporter, "emails", &closingWriter{client: exporter, close: 3}, nil)
+
+	require.Error(t, err)
+
+	// the server takes the held jobs back, none is hidden behind a long delay
+	require.Eventually(t, func() bool {
+		stats, err := c.StatsTube("emails")
+
+		return err == nil && stats.CurrentJobsReserved == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	for id := 1; id <= 3; id++ {
+		job, err := c.StatsJob(id)
+
+		require.NoError(t, err)
+		require.LessOrEqual(t, job.TimeLeft, 60)
+	}
+}
@@ -0,0 +1,135 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrLeaseLost = errors.New("beanstalk: lease: lost")
+
+// Lease keeps a reserved job from timing out by touching it periodically until
+// the lease is stopped. The context of the lease is cancelled with ErrLeaseLost
+// as the cause when a touch fails, e.g. the job is no longer reserved.
+type Lease struct {
+	client *Client
+	id     int
+	ttr    time.Duration
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	stopCh chan struct{}
+	doneCh chan struct{}
+	once   sync.Once
+}
+
+func NewLease(ctx context.Context, client *Client, id int) (*Lease, error) {
+	stats, err := client.StatsJobContext(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLease(ctx, client, stats), nil
+}
+
+func newLease(ctx context.Context, client *Client, stats *StatsJob) *Lease {
+	ttr := time.Duration(stats.TTR) * time.Second
+	if ttr < time.Second {
+		ttr = time.Second
+	}
+
+	leaseCtx, cancel := context.WithCancelCause(ctx)
+
+	l := &Lease{
+		client: client,
+		id:     stats.ID,
+		ttr:    ttr,
+		ctx:    leaseCtx,
+		cancel: cancel,
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+
+	go l.keepAlive()
+
+	return l
+}
+
+func (l *Lease) ID() int {
+	return l.id
+}
+
+func (l *Lease) TTR() time.Duration {
+	return l.ttr
+}
+
+func (l *Lease) Context() context.Context {
+	return l.ctx
+}
+
+// Err returns the reason the lease was lost, or nil.
+func (l *Lease) Err() error {
+	if cause := context.Cause(l.ctx); errors.Is(cause, ErrLeaseLost) {
+		return cause
+	}
+
+	return nil
+}
+
+// Stop stops touching the job and cancels the context of the lease.
+func (l *Lease) Stop() {
+	l.once.Do(func() {
+		close(l.stopCh)
+
+		<-l.doneCh
+
+		l.cancel(nil)
+	})
+}
+
+func (l *Lease) Delete(ctx context.Context) error {
+	l.Stop()
+
+	return l.client.DeleteContext(ctx, l.id)
+}
+
+func (l *Lease) Release(ctx context.Context, priority uint32, delay time.Duration) error {
+	l.Stop()
+
+	return l.client.ReleaseContext(ctx, l.id, priority, delay)
+}
+
+func (l *Lease) Bury(ctx context.Context, priority uint32) error {
+	l.Stop()
+
+	return l.client.BuryContext(ctx, l.id, priority)
+}
+
+func (l *Lease) keepAlive() {
+	defer close(l.doneCh)
+
+	// touches well before the server's one second safety margin
+	ticker := time.NewTicker(l.ttr / 2)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopCh:
+			return
+
+		case <-l.ctx.Done():
+			return
+
+		case <-ticker.C:
+			if err := l.client.TouchContext(l.ctx, l.id); err != nil {
+				if l.ctx.Err() == nil {
+					l.cancel(fmt.Errorf("%w: %w", ErrLeaseLost, err))
+				}
+
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package beanstalk_test
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const leaseStatsJob = "OK 28\r\n---\nid: 1\ntube: test\nttr: 1\n\r\n"
+
+func TestNewLease(t *testing.T) {
+	t.Run("not found", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn([]string{"stats-job 1\r\n"}, []string{"NOT_FOUND\r\n"}))
+
+		lease, err := beanstalk.NewLease(context.Background(), c, 1)
+
+		require.Equal(t, beanstalk.ErrNotFound, err)
+		require.Nil(t, lease)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("touch", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"stats-job 1\r\n", "touch 1\r\n", "delete 1\r\n"},
+			[]string{leaseStatsJob, "TOUCHED\r\n", "DELETED\r\n"},
+		))
+
+		lease, err := beanstalk.NewLease(context.Background(), c, 1)
+
+		require.Nil(t, err)
+		require.Equal(t, 1, lease.ID())
+		require.Equal(t, 1*time.Second, lease.TTR())
+
+		// waits for a single touch
+		time.Sleep(700 * time.Millisecond)
+
+		require.NoError(t, lease.Delete(context.Background()))
+		require.Nil(t, lease.Err())
+		require.Equal(t, context.Canceled, lease.Context().Err())
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("lost", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"stats-job 1\r\n", "touch 1\r\n"},
+			[]string{leaseStatsJob, "NOT_FOUND\r\n"},
+		))
+
+		lease, err := beanstalk.NewLease(context.Background(), c, 1)
+
+		require.Nil(t, err)
+
+		<-lease.Context().Done()
+
+		require.ErrorIs(t, lease.Err(), beanstalk.ErrLeaseLost)
+		require.ErrorIs(t, lease.Err(), beanstalk.ErrNotFound)
+
+		lease.Stop()
+
+		require.NoError(t, c.Close())
+	})
+}
+
+func TestWorker_KeepAlive(t *testing.T) {
+	var dials int32
+
+	lostCh := make(chan error, 1)
+	redialCh := make(chan struct{})
+
+	worker := beanstalk.NewWorker(
+		beanstalk.HandlerFunc(func(ctx context.Context, job *beanstalk.Job) error {
+			<-ctx.Done()
+
+			lostCh <- context.Cause(ctx)
+
+			return ctx.Err()
+		}),
+		&beanstalk.WorkerOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				if atomic.AddInt32(&dials, 1) > 1 {
+					close(redialCh)
+
+					return nil, io.EOF
+				}
+
+				// neither release nor delete is issued for a lost job
+				return beanstalk.NewClient(mock.NewConn(
+					[]string{"watch test\r\n", "ignore default\r\n", "reserve-with-timeout 5\r\n", "stats-job 1\r\n", "touch 1\r\n"},
+					[]string{"WATCHING 2\r\n", "WATCHING 1\r\n", "RESERVED 1 4\r\ntest\r\n", leaseStatsJob, "NOT_FOUND\r\n"},
+				)), nil
+			},
+			Tubes:          []string{"test"},
+			ReconnectDelay: 1 * time.Minute,
+			KeepAlive:      true,
+		},
+	)
+
+	require.NoError(t, worker.Start())
+
+	require.ErrorIs(t, <-lostCh, beanstalk.ErrLeaseLost)
+
+	<-redialCh
+
+	require.NoError(t, worker.Shutdown(context.Background()))
+}
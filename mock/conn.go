@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 )
 
 type ConnError struct {
@@ -18,8 +19,9 @@ func (e ConnError) Error() string {
 }
 
 type Conn struct {
-	in  []string
-	out []string
+	in    []string
+	out   []string
+	mutex sync.Mutex
 }
 
 func NewConn(in, out []string) io.ReadWriteCloser {
@@ -30,6 +32,9 @@ func NewConn(in, out []string) io.ReadWriteCloser {
 }
 
 func (c *Conn) Read(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if len(c.out) == 0 {
 		return 0, io.EOF
 	}
@@ -45,6 +50,9 @@ func (c *Conn) Read(b []byte) (int, error) {
 }
 
 func (c *Conn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if len(c.in) == 0 {
 		return 0, io.EOF
 	}
@@ -68,6 +76,9 @@ func (c *Conn) Write(b []byte) (int, error) {
 }
 
 func (c *Conn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	switch {
 	case len(c.in) > 0:
 		return errors.New("beanstalk: conn: input buffer is not empty")
@@ -0,0 +1,232 @@
+package mock
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type FaultOptions struct {
+	// delays every read
+	ReadDelay time.Duration
+	// returns at most ReadChunkSize bytes per read, splitting responses into chunks
+	ReadChunkSize int
+	// drops the connection once DropAfterRead bytes were read, e.g. in the middle of a job body
+	DropAfterRead int
+	// accepts at most WriteLimit bytes per write and fails with io.ErrShortWrite
+	WriteLimit int
+}
+
+// FaultConn wraps a connection and injects faults into its traffic. Once dropped,
+// reads return io.EOF and writes io.ErrClosedPipe, like a connection closed by the server.
+type FaultConn struct {
+	conn     io.ReadWriteCloser
+	options  *FaultOptions
+	read     int
+	dropped  bool
+	injected []string
+	pending  []byte
+	replies  []string
+	buffer   bytes.Buffer
+	mutex    sync.Mutex
+}
+
+func NewFaultConn(conn io.ReadWriteCloser, options *FaultOptions) *FaultConn {
+	return &FaultConn{
+		conn:    conn,
+		options: options,
+	}
+}
+
+// InjectResponse answers the next command with the given response line, e.g. "OUT_OF_MEMORY"
+// or "DRAINING", instead of passing the command to the wrapped connection. Responses to
+// commands written before the call must have been read already.
+func (c *FaultConn) InjectResponse(line string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.injected = append(c.injected, line)
+}
+
+// Drop closes the wrapped connection as if the server went away.
+func (c *FaultConn) Drop() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.drop()
+}
+
+func (c *FaultConn) Read(b []byte) (int, error) {
+	if c.options.ReadDelay > 0 {
+		time.Sleep(c.options.ReadDelay)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.dropped {
+		return 0, io.EOF
+	}
+
+	if c.buffer.Len() == 0 {
+		if err := c.fill(); err != nil {
+			return 0, err
+		}
+
+		if c.dropped {
+			return 0, io.EOF
+		}
+	}
+
+	if c.options.ReadChunkSize > 0 && len(b) > c.options.ReadChunkSize {
+		b = b[:c.options.ReadChunkSize]
+	}
+
+	if c.options.DropAfterRead > 0 && len(b) > c.options.DropAfterRead-c.read {
+		b = b[:c.options.DropAfterRead-c.read]
+	}
+
+	n, _ := c.buffer.Read(b)
+
+	c.read += n
+
+	if c.options.DropAfterRead > 0 && c.read >= c.options.DropAfterRead {
+		c.drop()
+	}
+
+	return n, nil
+}
+
+func (c *FaultConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.dropped {
+		return 0, io.ErrClosedPipe
+	}
+
+	n := len(b)
+	if c.options.WriteLimit > 0 && n > c.options.WriteLimit {
+		n = c.options.WriteLimit
+	}
+
+	data := b[:n]
+
+	if len(c.injected) > 0 || len(c.pending) > 0 {
+		data = c.swallow(data)
+	}
+
+	if len(data) > 0 {
+		if _, err := c.conn.Write(data); err != nil {
+			return 0, err
+		}
+	}
+
+	if n < len(b) {
+		return n, io.ErrShortWrite
+	}
+
+	return n, nil
+}
+
+func (c *FaultConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.dropped {
+		return nil
+	}
+
+	c.dropped = true
+
+	return c.conn.Close()
+}
+
+// swallow replaces the commands with injected responses and returns the bytes to pass through.
+func (c *FaultConn) swallow(b []byte) []byte {
+	c.pending = append(c.pending, b...)
+
+	for len(c.injected) > 0 {
+		n := commandLength(c.pending)
+		if n == 0 {
+			// waits for the rest of the command
+			return nil
+		}
+
+		c.pending = c.pending[n:]
+
+		c.replies = append(c.replies, c.injected[0]+"\r\n")
+		c.injected = c.injected[1:]
+	}
+
+	data := c.pending
+	c.pending = nil
+
+	return data
+}
+
+// fill buffers injected responses first, otherwise the next read of the wrapped
+// connection, which happens outside the lock so writes are not blocked meanwhile.
+func (c *FaultConn) fill() error {
+	if len(c.replies) > 0 {
+		c.buffer.WriteString(strings.Join(c.replies, ""))
+		c.replies = nil
+
+		return nil
+	}
+
+	c.mutex.Unlock()
+
+	b := make([]byte, 4096)
+	n, err := c.conn.Read(b)
+
+	c.mutex.Lock()
+
+	c.buffer.Write(b[:n])
+
+	if n == 0 && err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *FaultConn) drop() {
+	if c.dropped {
+		return
+	}
+
+	c.dropped = true
+
+	_ = c.conn.Close()
+}
+
+// commandLength returns the length of the first complete command, including the
+// body of a put, or zero if the command is incomplete.
+func commandLength(b []byte) int {
+	i := bytes.Index(b, []byte("\r\n"))
+	if i == -1 {
+		return 0
+	}
+
+	n := i + 2
+
+	fields := strings.Fields(string(b[:i]))
+	if len(fields) == 5 && fields[0] == "put" {
+		size, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return n
+		}
+
+		if len(b) < n+size+2 {
+			return 0
+		}
+
+		n += size + 2
+	}
+
+	return n
+}
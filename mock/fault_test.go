@@ -0,0 +1,145 @@
+package mock_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultConn(t *testing.T) {
+	t.Run("read delay", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewFaultConn(
+			mock.NewConn([]string{"use test\r\n"}, []string{"USING test\r\n"}),
+			&mock.FaultOptions{ReadDelay: 50 * time.Millisecond},
+		))
+
+		start := time.Now()
+
+		tube, err := c.Use("test")
+
+		require.Nil(t, err)
+		require.Equal(t, "test", tube)
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("read chunks", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewFaultConn(
+			mock.NewConn([]string{"reserve\r\n"}, []string{"RESERVED 1 4\r\ntest\r\n"}),
+			&mock.FaultOptions{ReadChunkSize: 1},
+		))
+
+		job, err := c.Reserve()
+
+		require.Nil(t, err)
+		require.Equal(t, &beanstalk.Job{ID: 1, Data: []byte("test")}, job)
+	})
+
+	t.Run("drop mid-body", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewFaultConn(
+			mock.NewConn([]string{"reserve\r\n"}, []string{"RESERVED 1 4\r\ntest\r\n"}),
+			&mock.FaultOptions{DropAfterRead: 16},
+		))
+
+		_, err := c.Reserve()
+
+		require.Equal(t, io.ErrUnexpectedEOF, err)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		conn := mock.NewFaultConn(mock.NewConn(nil, nil), &mock.FaultOptions{})
+
+		conn.Drop()
+
+		_, err := conn.Write([]byte("stats\r\n"))
+
+		require.Equal(t, io.ErrClosedPipe, err)
+
+		_, err = conn.Read(make([]byte, 8))
+
+		require.Equal(t, io.EOF, err)
+		require.NoError(t, conn.Close())
+	})
+
+	t.Run("partial write", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewFaultConn(
+			mock.NewConn([]string{"put 1"}, nil),
+			&mock.FaultOptions{WriteLimit: 5},
+		))
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Equal(t, io.ErrShortWrite, err)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("inject response", func(t *testing.T) {
+		conn := mock.NewFaultConn(
+			mock.NewConn([]string{"put 1 0 60 4\r\ntest\r\n"}, []string{"INSERTED 1\r\n"}),
+			&mock.FaultOptions{},
+		)
+
+		c := beanstalk.NewClient(conn)
+
+		conn.InjectResponse("OUT_OF_MEMORY")
+		conn.InjectResponse("DRAINING")
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Equal(t, beanstalk.ErrOutOfMemory, err)
+
+		_, err = c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Equal(t, beanstalk.ErrDraining, err)
+
+		id, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Nil(t, err)
+		require.Equal(t, 1, id)
+		require.Equal(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		conns := []*mock.FaultConn{
+			mock.NewFaultConn(mock.NewConn([]string{"stats-tube test\r\n"}, []string{"OK 10\r\n---\n"}), &mock.FaultOptions{DropAfterRead: 10}),
+			mock.NewFaultConn(mock.NewConn(nil, nil), &mock.FaultOptions{}),
+		}
+
+		dials := 0
+
+		pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				conn := conns[dials]
+				dials++
+
+				return beanstalk.NewClient(conn), nil
+			},
+			Capacity: 1,
+		})
+
+		require.NoError(t, pool.Open(context.Background()))
+
+		c, err := pool.Get()
+
+		require.NoError(t, err)
+
+		_, err = c.StatsTube("test")
+
+		require.Error(t, err)
+		require.NoError(t, pool.Put(c))
+
+		// the broken client is discarded and replaced by a new connection
+		c, err = pool.Get()
+
+		require.NoError(t, err)
+		require.Equal(t, 2, dials)
+		require.NoError(t, pool.Put(c))
+
+		require.NoError(t, pool.Close(context.Background()))
+	})
+}
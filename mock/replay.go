@@ -0,0 +1,222 @@
+package mock
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A recording holds one chunk of traffic per line, quoted like a Go string:
+//
+//	> "use test\r\n"
+//	< "USING test\r\n"
+//
+// where ">" is sent to the server and "<" received from it. Empty lines and lines
+// starting with "#" are ignored, so recordings may be annotated by hand.
+const (
+	sentPrefix     = "> "
+	receivedPrefix = "< "
+)
+
+// Recorder wraps a connection and writes its traffic to a recording.
+type Recorder struct {
+	conn  io.ReadWriteCloser
+	w     io.Writer
+	err   error
+	mutex sync.Mutex
+}
+
+func NewRecorder(conn io.ReadWriteCloser, w io.Writer) *Recorder {
+	return &Recorder{
+		conn: conn,
+		w:    w,
+	}
+}
+
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.conn.Read(b)
+	if n > 0 {
+		r.record(receivedPrefix, b[:n])
+	}
+
+	return n, err
+}
+
+// Write records the chunk before it is sent, so it precedes the response in the recording.
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.record(sentPrefix, b)
+
+	return r.conn.Write(b)
+}
+
+// Close closes the connection and reports the first failure to write the recording.
+func (r *Recorder) Close() error {
+	err := r.conn.Close()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+
+	return err
+}
+
+func (r *Recorder) record(prefix string, b []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	_, r.err = io.WriteString(r.w, prefix+strconv.Quote(string(b))+"\n")
+}
+
+type record struct {
+	sent bool
+	data []byte
+}
+
+// ReplayConn plays a recording back. Writes must match the sent traffic as a stream,
+// regardless of how it is chunked, and reads wait until the preceding writes arrived.
+type ReplayConn struct {
+	records []record
+	closed  bool
+	cond    *sync.Cond
+	mutex   sync.Mutex
+}
+
+func NewReplayConn(r io.Reader) (*ReplayConn, error) {
+	c := &ReplayConn{}
+	c.cond = sync.NewCond(&c.mutex)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var rec record
+
+		switch {
+		case strings.HasPrefix(text, sentPrefix):
+			rec.sent = true
+
+		case strings.HasPrefix(text, receivedPrefix):
+
+		default:
+			return nil, fmt.Errorf("beanstalk: replay: line %d: unknown direction", line)
+		}
+
+		data, err := strconv.Unquote(text[len(sentPrefix):])
+		if err != nil {
+			return nil, fmt.Errorf("beanstalk: replay: line %d: %w", line, err)
+		}
+
+		// merges chunks of the same direction into one stream
+		if n := len(c.records); n > 0 && c.records[n-1].sent == rec.sent {
+			c.records[n-1].data = append(c.records[n-1].data, data...)
+
+			continue
+		}
+
+		rec.data = []byte(data)
+		c.records = append(c.records, rec)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *ReplayConn) Read(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for {
+		if c.closed || len(c.records) == 0 {
+			return 0, io.EOF
+		}
+
+		if !c.records[0].sent {
+			break
+		}
+
+		c.cond.Wait()
+	}
+
+	n := copy(b, c.records[0].data)
+
+	c.consume(n)
+
+	return n, nil
+}
+
+func (c *ReplayConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	written := 0
+
+	for written < len(b) {
+		if c.closed {
+			return written, io.ErrClosedPipe
+		}
+
+		if len(c.records) == 0 || !c.records[0].sent {
+			return written, ConnError{expected: nil, actual: b[written:]}
+		}
+
+		expected := c.records[0].data
+		if len(expected) > len(b)-written {
+			expected = expected[:len(b)-written]
+		}
+
+		if !bytes.Equal(expected, b[written:written+len(expected)]) {
+			return written, ConnError{expected: expected, actual: b[written : written+len(expected)]}
+		}
+
+		written += len(expected)
+
+		c.consume(len(expected))
+	}
+
+	return written, nil
+}
+
+// Close fails if the recording was not played back entirely.
+func (c *ReplayConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.closed = true
+	c.cond.Broadcast()
+
+	if len(c.records) > 0 {
+		return errors.New("beanstalk: replay: recording is not played back entirely")
+	}
+
+	return nil
+}
+
+func (c *ReplayConn) consume(n int) {
+	c.records[0].data = c.records[0].data[n:]
+
+	if len(c.records[0].data) == 0 {
+		c.records = c.records[1:]
+	}
+
+	c.cond.Broadcast()
+}
@@ -0,0 +1,144 @@
+package mock_test
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	s := beanstalktest.NewServer()
+
+	defer s.Close()
+
+	session := func(c *beanstalk.Client) (*beanstalk.Job, *beanstalk.StatsJob, []string) {
+		_, err := c.Use("test")
+
+		require.Nil(t, err)
+
+		_, err = c.Put(1, 0, 1*time.Minute, []byte("hello\r\nworld\x00"))
+
+		require.Nil(t, err)
+
+		job, err := c.PeekReady()
+
+		require.Nil(t, err)
+
+		stats, err := c.StatsJob(job.ID)
+
+		require.Nil(t, err)
+
+		tubes, err := c.ListTubes()
+
+		require.Nil(t, err)
+
+		return job, stats, tubes
+	}
+
+	conn, err := net.Dial("tcp", s.Addr)
+
+	require.NoError(t, err)
+
+	var recording bytes.Buffer
+
+	c := beanstalk.NewClient(mock.NewRecorder(conn, &recording))
+
+	job, stats, tubes := session(c)
+
+	require.NoError(t, c.Close())
+	require.True(t, strings.HasPrefix(recording.String(), "> \"use test\\r\\n\"\n< \"USING test\\r\\n\"\n"))
+
+	replay, err := mock.NewReplayConn(&recording)
+
+	require.NoError(t, err)
+
+	c = beanstalk.NewClient(replay)
+
+	replayedJob, replayedStats, replayedTubes := session(c)
+
+	require.Equal(t, job, replayedJob)
+	require.Equal(t, stats, replayedStats)
+	require.Equal(t, tubes, replayedTubes)
+
+	require.NoError(t, c.Close())
+}
+
+func TestReplayConn(t *testing.T) {
+	t.Run("recording", func(t *testing.T) {
+		f, err := os.Open("testdata/beanstalkd-1.12.txt")
+
+		require.NoError(t, err)
+
+		defer f.Close()
+
+		replay, err := mock.NewReplayConn(f)
+
+		require.NoError(t, err)
+
+		c := beanstalk.NewClient(replay)
+
+		_, err = c.Use("emails")
+
+		require.Nil(t, err)
+
+		stats, err := c.StatsJob(7)
+
+		require.Nil(t, err)
+		require.Equal(t, &beanstalk.StatsJob{
+			ID:       7,
+			Tube:     "emails",
+			State:    "reserved",
+			Priority: 1024,
+			Age:      35,
+			TTR:      120,
+			TimeLeft: 118,
+			Reserves: 3,
+			Timeouts: 1,
+			Releases: 1,
+		}, stats)
+
+		tubes, err := c.ListTubes()
+
+		require.Nil(t, err)
+		require.Equal(t, []string{"default", "emails", "reports-daily"}, tubes)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		replay, err := mock.NewReplayConn(strings.NewReader("> \"use test\\r\\n\"\n< \"USING test\\r\\n\"\n"))
+
+		require.NoError(t, err)
+
+		c := beanstalk.NewClient(replay)
+
+		_, err = c.Use("other")
+
+		require.IsType(t, mock.ConnError{}, err)
+	})
+
+	t.Run("unplayed", func(t *testing.T) {
+		replay, err := mock.NewReplayConn(strings.NewReader("> \"stats\\r\\n\"\n"))
+
+		require.NoError(t, err)
+		require.EqualError(t, replay.Close(), "beanstalk: replay: recording is not played back entirely")
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := mock.NewReplayConn(strings.NewReader("# comment\n\n= \"stats\\r\\n\"\n"))
+
+		require.EqualError(t, err, "beanstalk: replay: line 3: unknown direction")
+
+		_, err = mock.NewReplayConn(strings.NewReader("> stats\n"))
+
+		require.Error(t, err)
+	})
+}
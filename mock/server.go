@@ -0,0 +1,387 @@
+package mock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ExpectationError lists the commands that matched no expectation and the expectations
+// that were not met, each with a readable diff against the closest counterpart.
+type ExpectationError struct {
+	Unexpected []string
+	Unmet      []string
+}
+
+func (e *ExpectationError) Error() string {
+	var b strings.Builder
+
+	b.WriteString("beanstalk: mock: expectations failed")
+
+	for _, s := range e.Unexpected {
+		b.WriteString("\n\tunexpected command:\n" + s)
+	}
+
+	for _, s := range e.Unmet {
+		b.WriteString("\n\tunmet expectation: " + s)
+	}
+
+	return b.String()
+}
+
+type command struct {
+	name string
+	args []string
+	body []byte
+	tube string
+}
+
+func (c command) String() string {
+	switch c.name {
+	case "put":
+		return fmt.Sprintf("put to %q with body %q", c.tube, c.body)
+
+	default:
+		return strings.Join(append([]string{c.name}, c.args...), " ")
+	}
+}
+
+type Expectation struct {
+	description string
+	name        string
+	match       func(c command) bool
+	response    func(c command) string
+	times       int
+	calls       int
+}
+
+// Times sets how many times the command is expected, once by default.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+
+	return e
+}
+
+// Respond answers the command with the given response line, e.g. "NOT_FOUND" or "OUT_OF_MEMORY".
+func (e *Expectation) Respond(line string) *Expectation {
+	e.response = func(command) string {
+		return line + "\r\n"
+	}
+
+	return e
+}
+
+func (e *Expectation) String() string {
+	return e.description
+}
+
+type PutExpectation struct {
+	*Expectation
+}
+
+// Return answers the put with the given job id.
+func (e *PutExpectation) Return(id int) *PutExpectation {
+	e.Respond("INSERTED " + strconv.Itoa(id))
+
+	return e
+}
+
+type ReserveExpectation struct {
+	*Expectation
+}
+
+// Return answers the reserve with the given job.
+func (e *ReserveExpectation) Return(id int, body []byte) *ReserveExpectation {
+	e.response = func(command) string {
+		return fmt.Sprintf("RESERVED %d %d\r\n%s\r\n", id, len(body), body)
+	}
+
+	return e
+}
+
+// Server is a connection that answers commands from registered expectations in any
+// order. The tube commands use, watch, ignore and list-tube-used are answered from
+// the tracked state without expectations. Close reports unexpected commands and unmet
+// expectations as an *ExpectationError.
+type Server struct {
+	expectations []*Expectation
+	unexpected   []string
+	used         string
+	watched      []string
+	nextID       int
+	in           []byte
+	out          bytes.Buffer
+	closed       bool
+	cond         *sync.Cond
+	mutex        sync.Mutex
+}
+
+func NewServer() *Server {
+	s := &Server{
+		used:    "default",
+		watched: []string{"default"},
+		nextID:  1,
+	}
+
+	s.cond = sync.NewCond(&s.mutex)
+
+	return s
+}
+
+// Expect registers a command without a body by its exact line, e.g. "kick-job 1",
+// it is answered with INTERNAL_ERROR unless Respond is used.
+func (s *Server) Expect(line string) *Expectation {
+	fields := strings.Fields(line)
+
+	name := ""
+	if len(fields) > 0 {
+		name = fields[0]
+	}
+
+	e := s.expect(&Expectation{
+		description: line,
+		name:        name,
+		match: func(c command) bool {
+			return c.String() == strings.Join(fields, " ")
+		},
+	})
+
+	return e.Respond("INTERNAL_ERROR")
+}
+
+// ExpectPut registers a put of the body to the tube, it is answered with a new id unless Return is used.
+func (s *Server) ExpectPut(tube string, body []byte) *PutExpectation {
+	e := s.expect(&Expectation{
+		description: command{name: "put", tube: tube, body: body}.String(),
+		name:        "put",
+		match: func(c command) bool {
+			return c.tube == tube && bytes.Equal(c.body, body)
+		},
+		response: func(command) string {
+			id := s.nextID
+			s.nextID++
+
+			return "INSERTED " + strconv.Itoa(id) + "\r\n"
+		},
+	})
+
+	return &PutExpectation{e}
+}
+
+// ExpectReserve registers a reserve or reserve-with-timeout, it is answered with TIMED_OUT unless Return is used.
+func (s *Server) ExpectReserve() *ReserveExpectation {
+	e := s.expect(&Expectation{
+		description: "reserve",
+		name:        "reserve",
+		match: func(c command) bool {
+			return c.name == "reserve" || c.name == "reserve-with-timeout"
+		},
+	})
+
+	e.Respond("TIMED_OUT")
+
+	return &ReserveExpectation{e}
+}
+
+func (s *Server) ExpectDelete(id int) *Expectation {
+	return s.expectJob("delete", id, "DELETED")
+}
+
+func (s *Server) ExpectRelease(id int) *Expectation {
+	return s.expectJob("release", id, "RELEASED")
+}
+
+func (s *Server) ExpectBury(id int) *Expectation {
+	return s.expectJob("bury", id, "BURIED")
+}
+
+func (s *Server) ExpectTouch(id int) *Expectation {
+	return s.expectJob("touch", id, "TOUCHED")
+}
+
+// expectJob registers a command on a job id regardless of its other arguments.
+func (s *Server) expectJob(name string, id int, response string) *Expectation {
+	e := s.expect(&Expectation{
+		description: name + " " + strconv.Itoa(id),
+		name:        name,
+		match: func(c command) bool {
+			return len(c.args) > 0 && c.args[0] == strconv.Itoa(id)
+		},
+	})
+
+	return e.Respond(response)
+}
+
+func (s *Server) expect(e *Expectation) *Expectation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e.times = 1
+
+	s.expectations = append(s.expectations, e)
+
+	return e
+}
+
+// Read blocks until a response is available, so pipelined commands may be read concurrently.
+func (s *Server) Read(b []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for s.out.Len() == 0 {
+		if s.closed {
+			return 0, io.EOF
+		}
+
+		s.cond.Wait()
+	}
+
+	return s.out.Read(b)
+}
+
+func (s *Server) Write(b []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	s.in = append(s.in, b...)
+
+	for {
+		n := commandLength(s.in)
+		if n == 0 {
+			break
+		}
+
+		s.out.WriteString(s.handle(s.parse(s.in[:n])))
+
+		s.in = s.in[n:]
+	}
+
+	s.cond.Broadcast()
+
+	return len(b), nil
+}
+
+// Close verifies that every expectation was met and no unexpected command was received.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	s.cond.Broadcast()
+
+	err := &ExpectationError{Unexpected: s.unexpected}
+
+	for _, e := range s.expectations {
+		if e.calls != e.times {
+			err.Unmet = append(err.Unmet, fmt.Sprintf("%s: expected %d time(s), got %d", e, e.times, e.calls))
+		}
+	}
+
+	if len(err.Unexpected) == 0 && len(err.Unmet) == 0 {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Server) parse(b []byte) command {
+	i := bytes.Index(b, []byte("\r\n"))
+
+	fields := strings.Fields(string(b[:i]))
+	if len(fields) == 0 {
+		return command{}
+	}
+
+	c := command{name: fields[0], args: fields[1:], tube: s.used}
+
+	if c.name == "put" && len(b) >= i+4 {
+		c.body = b[i+2 : len(b)-2]
+	}
+
+	return c
+}
+
+func (s *Server) handle(c command) string {
+	switch {
+	case c.name == "use" && len(c.args) == 1:
+		s.used = c.args[0]
+
+		return "USING " + s.used + "\r\n"
+
+	case c.name == "list-tube-used":
+		return "USING " + s.used + "\r\n"
+
+	case c.name == "watch" && len(c.args) == 1:
+		if !contains(s.watched, c.args[0]) {
+			s.watched = append(s.watched, c.args[0])
+		}
+
+		return "WATCHING " + strconv.Itoa(len(s.watched)) + "\r\n"
+
+	case c.name == "ignore" && len(c.args) == 1:
+		if len(s.watched) == 1 && s.watched[0] == c.args[0] {
+			return "NOT_IGNORED\r\n"
+		}
+
+		for i, tube := range s.watched {
+			if tube == c.args[0] {
+				s.watched = append(s.watched[:i], s.watched[i+1:]...)
+
+				break
+			}
+		}
+
+		return "WATCHING " + strconv.Itoa(len(s.watched)) + "\r\n"
+	}
+
+	var closest, exhausted *Expectation
+
+	for _, e := range s.expectations {
+		if e.name != c.name && !(e.name == "reserve" && c.name == "reserve-with-timeout") {
+			continue
+		}
+
+		switch {
+		case !e.match(c):
+			closest = e
+
+		case e.calls >= e.times:
+			exhausted = e
+
+		default:
+			e.calls++
+
+			return e.response(c)
+		}
+	}
+
+	switch {
+	case exhausted != nil:
+		s.unexpected = append(s.unexpected, fmt.Sprintf("\t\t%s: expected %d time(s), got more", exhausted, exhausted.times))
+
+	case closest != nil:
+		s.unexpected = append(s.unexpected, "\t\t- "+closest.String()+"\n\t\t+ "+c.String())
+
+	default:
+		s.unexpected = append(s.unexpected, "\t\t+ "+c.String())
+	}
+
+	return "INTERNAL_ERROR\r\n"
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
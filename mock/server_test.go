@@ -0,0 +1,130 @@
+package mock_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s := mock.NewServer()
+
+		s.ExpectPut("test", []byte("b")).Return(2)
+		s.ExpectPut("test", []byte("a")).Return(1)
+		s.ExpectReserve().Return(1, []byte("a")).Times(2)
+		s.ExpectDelete(1)
+		s.ExpectRelease(1)
+		s.Expect("kick-job 3").Respond("NOT_FOUND")
+
+		c := beanstalk.NewClient(s)
+
+		_, err := c.Use("test")
+
+		require.Nil(t, err)
+
+		// the expectations are matched in any order
+		id, err := c.Put(1, 0, 1*time.Minute, []byte("a"))
+
+		require.Nil(t, err)
+		require.Equal(t, 1, id)
+
+		id, err = c.Put(1, 0, 1*time.Minute, []byte("b"))
+
+		require.Nil(t, err)
+		require.Equal(t, 2, id)
+
+		for i := 0; i < 2; i++ {
+			job, err := c.ReserveWithTimeout(1 * time.Second)
+
+			require.Nil(t, err)
+			require.Equal(t, &beanstalk.Job{ID: 1, Data: []byte("a")}, job)
+		}
+
+		require.Nil(t, c.Release(1, 1, 0))
+		require.Nil(t, c.Delete(1))
+		require.Equal(t, beanstalk.ErrNotFound, c.KickJob(3))
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("tube commands", func(t *testing.T) {
+		s := mock.NewServer()
+
+		c := beanstalk.NewClient(s)
+
+		count, err := c.Watch("test")
+
+		require.Nil(t, err)
+		require.Equal(t, 2, count)
+
+		_, err = c.Ignore("default")
+
+		require.Nil(t, err)
+
+		_, err = c.Ignore("test")
+
+		require.Equal(t, beanstalk.ErrNotIgnored, err)
+
+		tube, err := c.ListTubeUsed()
+
+		require.Nil(t, err)
+		require.Equal(t, "default", tube)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("pipeline", func(t *testing.T) {
+		s := mock.NewServer()
+
+		s.ExpectDelete(1)
+		s.ExpectDelete(2).Respond("NOT_FOUND")
+
+		results, err := beanstalk.NewClient(s).Pipeline().
+			Add(beanstalk.DeleteCommand{ID: 2}).
+			Add(beanstalk.DeleteCommand{ID: 1}).
+			Execute()
+
+		require.Nil(t, err)
+		require.Equal(t, beanstalk.ErrNotFound, results[0].Err)
+		require.Nil(t, results[1].Err)
+
+		require.NoError(t, s.Close())
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		s := mock.NewServer()
+
+		s.ExpectPut("test", []byte("hello"))
+		s.ExpectTouch(1)
+		s.ExpectBury(1).Times(0)
+
+		c := beanstalk.NewClient(s)
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("hello"))
+
+		require.Equal(t, beanstalk.ErrInternalError, err)
+		require.Equal(t, beanstalk.ErrInternalError, c.Bury(1, 1))
+		require.Equal(t, beanstalk.ErrInternalError, c.Delete(1))
+
+		err = c.Close()
+
+		var expectationErr *mock.ExpectationError
+
+		require.True(t, errors.As(err, &expectationErr))
+		require.Equal(t, []string{
+			"\t\t- put to \"test\" with body \"hello\"\n\t\t+ put to \"default\" with body \"hello\"",
+			"\t\tbury 1: expected 0 time(s), got more",
+			"\t\t+ delete 1",
+		}, expectationErr.Unexpected)
+		require.Equal(t, []string{
+			"put to \"test\" with body \"hello\": expected 1 time(s), got 0",
+			"touch 1: expected 1 time(s), got 0",
+		}, expectationErr.Unmet)
+		require.Contains(t, err.Error(), "unexpected command:\n\t\t- put to \"test\"")
+	})
+}
@@ -1,8 +1,13 @@
 package beanstalk
 
 type Job struct {
-	ID   int
+	ID int
+	// is the payload without the envelope for reserved and peeked jobs, see Headers
 	Data []byte
+	// is the name of the tube the job was reserved from, it is resolved by the worker only
+	Tube string
+	// are the headers of a reserved or peeked envelope, whose body is in Data then
+	headers Headers
 }
 
 type StatsJob struct {
@@ -0,0 +1,134 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrSameTube = errors.New("beanstalk: move: source and destination tube are the same")
+
+type MoveOptions struct {
+	// selects the jobs to move by their payload and statistics, all jobs by default
+	Filter func(job *Job, stats *StatsJob) bool
+	// limits the number of moved jobs, all by default
+	Limit int
+	// is ready, delayed and buried by default
+	States []string
+	// keeps the originals, so the jobs are copied
+	Copy bool
+	// reports the matching jobs without moving them
+	DryRun bool
+}
+
+type MovedJob struct {
+	// is the id of the original job
+	ID int
+	// is the id in the destination tube, zero for dry runs
+	NewID int
+	// is the state the job was moved in
+	State string
+}
+
+// MoveJobs moves the jobs of the source tube to the destination tube, keeping their state,
+// priority, TTR and delay left. The jobs are moved one by one: a matching job is reserved by
+// id, put into the destination tube and deleted only once the put succeeded. Other jobs are
+// stepped past as ExportTube does. The used tube of the client is restored.
+func MoveJobs(ctx context.Context, c *Client, src, dst string, options *MoveOptions) ([]MovedJob, error) {
+	if options == nil {
+		options = &MoveOptions{}
+	}
+
+	if src == dst {
+		return nil, ErrSameTube
+	}
+
+	states := options.States
+	if len(states) == 0 {
+		states = []string{ReadyState, DelayedState, BuriedState}
+	}
+
+	for _, state := range states {
+		if state != ReadyState && state != DelayedState && state != BuriedState {
+			return nil, fmt.Errorf("beanstalk: move: unknown state %q", state)
+		}
+	}
+
+	var moved []MovedJob
+
+	err := scanJobs(ctx, c, src, states, func(s *scannedJob) (bool, error) {
+		if options.Filter != nil && !options.Filter(s.job, s.stats) {
+			return false, nil
+		}
+
+		id := 0
+
+		if !options.DryRun {
+			var err error
+
+			id, err = moveJob(ctx, c, s, src, dst, options.Copy)
+			if errors.Is(err, ErrNotFound) {
+				// reserved by a worker or deleted since the peek
+				return true, nil
+			}
+
+			if err != nil && id != 0 {
+				moved = append(moved, MovedJob{ID: s.job.ID, NewID: id, State: s.state})
+			}
+
+			if err != nil {
+				return false, fmt.Errorf("beanstalk: move: job %d: %w", s.job.ID, err)
+			}
+		}
+
+		moved = append(moved, MovedJob{ID: s.job.ID, NewID: id, State: s.state})
+
+		// the originals of moved jobs are gone, the others are stepped past
+		taken := !options.DryRun && !options.Copy
+
+		if options.Limit > 0 && len(moved) >= options.Limit {
+			return taken, errStopScan
+		}
+
+		return taken, nil
+	})
+
+	return moved, err
+}
+
+// moveJob puts the job into dst and deletes the original, which is reserved meanwhile, unless
+// it is copied. The used tube is src again afterwards.
+func moveJob(ctx context.Context, c *Client, s *scannedJob, src, dst string, copy bool) (int, error) {
+	if !copy {
+		if _, err := c.ReserveJobContext(ctx, s.job.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err := c.UseContext(ctx, dst)
+
+	id := 0
+	if err == nil {
+		id, err = putExported(ctx, c, s.export())
+	}
+
+	if _, useErr := c.UseContext(context.WithoutCancel(ctx), src); err == nil {
+		err = useErr
+	}
+
+	if id == 0 {
+		if !copy {
+			_ = s.restore(context.WithoutCancel(ctx), c)
+		}
+
+		return 0, err
+	}
+
+	if !copy {
+		if err := c.DeleteContext(ctx, s.job.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return id, err
+		}
+	}
+
+	return id, err
+}
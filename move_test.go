@@ -0,0 +1,172 @@
+package beanstalk_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveJobs(t *testing.T) {
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: beanstalktest.NewFakeClock(time.Now())})
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	fillTube(t, c, "emails")
+
+	ctx := context.Background()
+
+	tubeStats := func(tube string) *beanstalk.StatsTube {
+		stats, err := c.StatsTube(tube)
+
+		require.NoError(t, err)
+
+		return stats
+	}
+
+	t.Run("DryRun", func(t *testing.T) {
+		moved, err := beanstalk.MoveJobs(ctx, c, "emails", "archive", &beanstalk.MoveOptions{DryRun: true})
+
+		require.NoError(t, err)
+		require.Equal(t, []beanstalk.MovedJob{
+			{ID: 1, State: beanstalk.ReadyState},
+			{ID: 2, State: beanstalk.DelayedState},
+			{ID: 3, State: beanstalk.BuriedState},
+		}, moved)
+
+		stats := tubeStats("emails")
+
+		require.Equal(t, 1, stats.CurrentJobsReady)
+		require.Equal(t, 1, stats.CurrentJobsDelayed)
+		require.Equal(t, 1, stats.CurrentJobsBuried)
+		require.Equal(t, 0, stats.CurrentJobsReserved)
+	})
+
+	t.Run("Copy", func(t *testing.T) {
+		moved, err := beanstalk.MoveJobs(ctx, c, "emails", "backup", &beanstalk.MoveOptions{
+			Copy:   true,
+			States: []string{beanstalk.BuriedState},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, []beanstalk.MovedJob{{ID: 3, NewID: 4, State: beanstalk.BuriedState}}, moved)
+		require.Equal(t, 1, tubeStats("emails").CurrentJobsBuried)
+		require.Equal(t, 1, tubeStats("backup").CurrentJobsBuried)
+
+		job, err := c.StatsJob(4)
+
+		require.NoError(t, err)
+		require.Equal(t, 7, job.Priority)
+		require.Equal(t, 30, job.TTR)
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		moved, err := beanstalk.MoveJobs(ctx, c, "emails", "archive", &beanstalk.MoveOptions{
+			Filter: func(job *beanstalk.Job, stats *beanstalk.StatsJob) bool {
+				return bytes.HasPrefix(job.Data, []byte("delayed"))
+			},
+		})
+
+		require.NoError(t, err)
+		require.Equal(t, []beanstalk.MovedJob{{ID: 2, NewID: 5, State: beanstalk.DelayedState}}, moved)
+
+		job, err := c.StatsJob(5)
+
+		require.NoError(t, err)
+		require.Equal(t, "archive", job.Tube)
+		require.Equal(t, beanstalk.DelayedState, job.State)
+		require.Equal(t, 6, job.Priority)
+		require.Equal(t, 60, job.TimeLeft)
+
+		_, err = c.StatsJob(2)
+
+		require.ErrorIs(t, err, beanstalk.ErrNotFound)
+
+		stats := tubeStats("emails")
+
+		require.Equal(t, 1, stats.CurrentJobsReady)
+		require.Equal(t, 0, stats.CurrentJobsDelayed)
+		require.Equal(t, 1, stats.CurrentJobsBuried)
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		moved, err := beanstalk.MoveJobs(ctx, c, "emails", "archive", &beanstalk.MoveOptions{Limit: 1})
+
+		require.NoError(t, err)
+		require.Equal(t, []beanstalk.MovedJob{{ID: 1, NewID: 6, State: beanstalk.ReadyState}}, moved)
+
+		stats := tubeStats("emails")
+
+		require.Equal(t, 0, stats.CurrentJobsReady)
+		require.Equal(t, 1, stats.CurrentJobsBuried)
+		require.Equal(t, 0, stats.CurrentJobsReserved)
+
+		used, err := c.ListTubeUsed()
+
+		require.NoError(t, err)
+		require.Equal(t, "default", used)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := beanstalk.MoveJobs(ctx, c, "emails", "emails", nil)
+
+		require.ErrorIs(t, err, beanstalk.ErrSameTube)
+
+		_, err = beanstalk.MoveJobs(ctx, c, "emails", "archive", &beanstalk.MoveOptions{States: []string{"reserved"}})
+
+		require.EqualError(t, err, `beanstalk: move: unknown state "reserved"`)
+	})
+}
+
+func TestMoveJobs_Expiry(t *testing.T) {
+	clock := beanstalktest.NewFakeClock(time.Now())
+
+	s := beanstalktest.NewServerWithOptions(&beanstalktest.ServerOptions{Clock: clock})
+
+	defer s.Close()
+
+	c, err := beanstalk.Dial(s.Addr)
+
+	require.NoError(t, err)
+
+	defer c.Close()
+
+	for _, data := range []string{"first", "second", "third"} {
+		_, err := c.Put(5, 0, 1*time.Second, []byte(data))
+
+		require.NoError(t, err)
+	}
+
+	// the TTR of the jobs runs out while each job is selected
+	moved, err := beanstalk.MoveJobs(context.Background(), c, "default", "archive", &beanstalk.MoveOptions{
+		Filter: func(job *beanstalk.Job, stats *beanstalk.StatsJob) bool {
+			clock.Advance(2 * time.Second)
+
+			return !bytes.Equal(job.Data, []byte("second"))
+		},
+	})
+
+	require.NoError(t, err)
+	require.Len(t, moved, 2)
+
+	stats, err := c.StatsTube("default")
+
+	require.NoError(t, err)
+	require.Equal(t, 1, stats.CurrentJobsReady)
+	require.Equal(t, 0, stats.CurrentJobsReserved)
+
+	stats, err = c.StatsTube("archive")
+
+	require.NoError(t, err)
+	require.Equal(t, 2, stats.CurrentJobsReady)
+}
@@ -0,0 +1,141 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var ErrHandlerNotFound = errors.New("beanstalk: mux: handler not found")
+
+type muxEntry struct {
+	pattern string
+	handler Handler
+}
+
+// ServeMux dispatches reserved jobs to the handler registered for their tube.
+// Patterns are either exact tube names or globs in the path.Match syntax, exact
+// names take precedence over globs, globs are matched in registration order.
+type ServeMux struct {
+	mutex    sync.RWMutex
+	exact    map[string]Handler
+	patterns []muxEntry
+}
+
+func NewServeMux() *ServeMux {
+	return &ServeMux{
+		exact: make(map[string]Handler),
+	}
+}
+
+func (m *ServeMux) Handle(pattern string, handler Handler) {
+	if pattern == "" {
+		panic("beanstalk: mux: empty pattern")
+	}
+
+	if handler == nil {
+		panic("beanstalk: mux: nil handler")
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Sprintf("beanstalk: mux: invalid pattern %q: %v", pattern, err))
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !isGlob(pattern) {
+		if _, ok := m.exact[pattern]; ok {
+			panic(fmt.Sprintf("beanstalk: mux: multiple registrations for %q", pattern))
+		}
+
+		m.exact[pattern] = handler
+
+		return
+	}
+
+	for _, entry := range m.patterns {
+		if entry.pattern == pattern {
+			panic(fmt.Sprintf("beanstalk: mux: multiple registrations for %q", pattern))
+		}
+	}
+
+	m.patterns = append(m.patterns, muxEntry{pattern: pattern, handler: handler})
+}
+
+func (m *ServeMux) HandleFunc(pattern string, handler func(ctx context.Context, job *Job) error) {
+	m.Handle(pattern, HandlerFunc(handler))
+}
+
+func (m *ServeMux) Handler(tube string) (Handler, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if handler, ok := m.exact[tube]; ok {
+		return handler, true
+	}
+
+	for _, entry := range m.patterns {
+		if ok, _ := path.Match(entry.pattern, tube); ok {
+			return entry.handler, true
+		}
+	}
+
+	return nil, false
+}
+
+// Tubes returns the registered tube names along with the existing tubes matching
+// the glob patterns. Tubes created later are picked up on the next connection only.
+func (m *ServeMux) Tubes(ctx context.Context, client *Client) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	tubes := make([]string, 0, len(m.exact))
+	for tube := range m.exact {
+		tubes = append(tubes, tube)
+	}
+
+	if len(m.patterns) > 0 {
+		existing, err := client.ListTubesContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tube := range existing {
+			if _, ok := m.exact[tube]; ok {
+				continue
+			}
+
+			for _, entry := range m.patterns {
+				if ok, _ := path.Match(entry.pattern, tube); ok {
+					tubes = append(tubes, tube)
+
+					break
+				}
+			}
+		}
+	}
+
+	sort.Strings(tubes)
+
+	return tubes, nil
+}
+
+// ServeJob runs the handler registered for the tube of the job. Jobs without a
+// handler fail permanently, so the worker buries them.
+func (m *ServeMux) ServeJob(ctx context.Context, job *Job) error {
+	handler, ok := m.Handler(job.Tube)
+	if !ok {
+		return Permanent(fmt.Errorf("%w: %q", ErrHandlerNotFound, job.Tube))
+	}
+
+	return handler.ServeJob(ctx, job)
+}
+
+func isGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
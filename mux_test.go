@@ -0,0 +1,176 @@
+package beanstalk_test
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeMux_Handle(t *testing.T) {
+	noop := func(ctx context.Context, job *beanstalk.Job) error { return nil }
+
+	t.Run("empty pattern", func(t *testing.T) {
+		require.Panics(t, func() { beanstalk.NewServeMux().HandleFunc("", noop) })
+	})
+
+	t.Run("nil handler", func(t *testing.T) {
+		require.Panics(t, func() { beanstalk.NewServeMux().Handle("test", nil) })
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		require.Panics(t, func() { beanstalk.NewServeMux().HandleFunc("test[", noop) })
+	})
+
+	t.Run("multiple registrations", func(t *testing.T) {
+		mux := beanstalk.NewServeMux()
+		mux.HandleFunc("test", noop)
+		mux.HandleFunc("test-*", noop)
+
+		require.Panics(t, func() { mux.HandleFunc("test", noop) })
+		require.Panics(t, func() { mux.HandleFunc("test-*", noop) })
+	})
+}
+
+func TestServeMux_ServeJob(t *testing.T) {
+	var served string
+
+	mux := beanstalk.NewServeMux()
+	mux.HandleFunc("emails", func(ctx context.Context, job *beanstalk.Job) error {
+		served = "emails"
+
+		return nil
+	})
+	mux.HandleFunc("emails-*", func(ctx context.Context, job *beanstalk.Job) error {
+		served = "emails-*"
+
+		return nil
+	})
+	mux.HandleFunc("*", func(ctx context.Context, job *beanstalk.Job) error {
+		served = "*"
+
+		return nil
+	})
+
+	testCases := []struct {
+		tube     string
+		expected string
+	}{
+		{"emails", "emails"},
+		{"emails-high", "emails-*"},
+		{"reports", "*"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.tube, func(t *testing.T) {
+			require.NoError(t, mux.ServeJob(context.Background(), &beanstalk.Job{ID: 1, Tube: testCase.tube}))
+			require.Equal(t, testCase.expected, served)
+		})
+	}
+
+	t.Run("handler not found", func(t *testing.T) {
+		err := beanstalk.NewServeMux().ServeJob(context.Background(), &beanstalk.Job{ID: 1, Tube: "test"})
+
+		require.ErrorIs(t, err, beanstalk.ErrHandlerNotFound)
+		require.True(t, beanstalk.IsPermanent(err))
+	})
+}
+
+func TestServeMux_Tubes(t *testing.T) {
+	noop := func(ctx context.Context, job *beanstalk.Job) error { return nil }
+
+	t.Run("exact", func(t *testing.T) {
+		mux := beanstalk.NewServeMux()
+		mux.HandleFunc("reports", noop)
+		mux.HandleFunc("emails", noop)
+
+		c := beanstalk.NewClient(mock.NewConn(nil, nil))
+
+		tubes, err := mux.Tubes(context.Background(), c)
+
+		require.Nil(t, err)
+		require.Equal(t, []string{"emails", "reports"}, tubes)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		mux := beanstalk.NewServeMux()
+		mux.HandleFunc("emails", noop)
+		mux.HandleFunc("emails-*", noop)
+
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"list-tubes\r\n"},
+			[]string{"OK 50\r\n---\n- default\n- emails\n- emails-high\n- emails-low\n\r\n"},
+		))
+
+		tubes, err := mux.Tubes(context.Background(), c)
+
+		require.Nil(t, err)
+		require.Equal(t, []string{"emails", "emails-high", "emails-low"}, tubes)
+
+		require.NoError(t, c.Close())
+	})
+}
+
+func TestWorker_ServeMux(t *testing.T) {
+	var dials int32
+
+	tubeCh := make(chan string, 1)
+	redialCh := make(chan struct{})
+
+	mux := beanstalk.NewServeMux()
+	mux.HandleFunc("emails", func(ctx context.Context, job *beanstalk.Job) error {
+		tubeCh <- "emails:" + job.Tube
+
+		return nil
+	})
+	mux.HandleFunc("reports", func(ctx context.Context, job *beanstalk.Job) error {
+		tubeCh <- "reports:" + job.Tube
+
+		return nil
+	})
+
+	worker := beanstalk.NewWorker(mux, &beanstalk.WorkerOptions{
+		Dialer: func() (*beanstalk.Client, error) {
+			if atomic.AddInt32(&dials, 1) > 1 {
+				close(redialCh)
+
+				return nil, io.EOF
+			}
+
+			return beanstalk.NewClient(mock.NewConn(
+				[]string{
+					"watch emails\r\n",
+					"watch reports\r\n",
+					"ignore default\r\n",
+					"reserve-with-timeout 5\r\n",
+					"stats-job 7\r\n",
+					"delete 7\r\n",
+				},
+				[]string{
+					"WATCHING 2\r\n",
+					"WATCHING 3\r\n",
+					"WATCHING 2\r\n",
+					"RESERVED 7 4\r\ntest\r\n",
+					"OK 24\r\n---\nid: 7\ntube: reports\n\r\n",
+					"DELETED\r\n",
+				},
+			)), nil
+		},
+		ReconnectDelay: 1 * time.Minute,
+	})
+
+	require.NoError(t, worker.Start())
+
+	require.Equal(t, "reports:reports", <-tubeCh)
+
+	<-redialCh
+
+	require.NoError(t, worker.Shutdown(context.Background()))
+}
@@ -0,0 +1,146 @@
+package beanstalk
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type PipelineResult struct {
+	Response CommandResponse
+	Err      error
+}
+
+// Pipeline sends queued commands back-to-back on the connection of the client
+// and collects their responses in order, paying a single round trip per batch.
+type Pipeline struct {
+	client   *Client
+	commands []Command
+}
+
+func (c *Client) Pipeline() *Pipeline {
+	return &Pipeline{client: c}
+}
+
+func (p *Pipeline) Add(command Command) *Pipeline {
+	p.commands = append(p.commands, command)
+
+	return p
+}
+
+func (p *Pipeline) Len() int {
+	return len(p.commands)
+}
+
+func (p *Pipeline) Reset() {
+	p.commands = p.commands[:0]
+}
+
+func (p *Pipeline) Execute() ([]PipelineResult, error) {
+	return p.ExecuteContext(context.Background())
+}
+
+// ExecuteContext returns one result per queued command. Protocol errors, e.g.
+// ErrNotFound, are reported per command, while an I/O failure closes the client,
+// is returned as the error and is set on every command without a response.
+func (p *Pipeline) ExecuteContext(ctx context.Context) ([]PipelineResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]PipelineResult, len(p.commands))
+	if len(p.commands) == 0 {
+		return results, nil
+	}
+
+	c := p.client
+
+	// offloads by BlobThreshold only, a rejected put can not be retried within the batch
+	commands := make([]Command, len(p.commands))
+	keys := make([]string, len(p.commands))
+
+	for i, command := range p.commands {
+		var err error
+		if commands[i], keys[i], err = c.offload(ctx, c.compress(command), false); err != nil {
+			for _, key := range keys[:i] {
+				c.discardBlob(key)
+			}
+
+			return nil, err
+		}
+	}
+
+	if err := c.acquire(commands...); err != nil {
+		for _, key := range keys {
+			c.discardBlob(key)
+		}
+
+		return nil, err
+	}
+
+	defer c.release()
+
+	atomic.StoreInt64(&c.usedAt, time.Now().Unix())
+
+	id := c.conn.Next()
+
+	var (
+		once     sync.Once
+		firstErr error
+	)
+
+	// records the root cause and closes the client to unblock the other side
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+
+			_ = c.Close()
+		})
+	}
+
+	doneCh := make(chan struct{})
+
+	// writes concurrently with reading, otherwise a large batch deadlocks once
+	// the server stops reading because its responses are not consumed
+	go func() {
+		defer close(doneCh)
+
+		if err := c.writeRequest(ctx, id, commands...); err != nil {
+			fail(err)
+		}
+	}()
+
+	responses, err := c.readResponse(ctx, id, commands...)
+	if err != nil {
+		fail(err)
+	}
+
+	<-doneCh
+
+	err = firstErr
+
+	for i, response := range responses {
+		results[i].Response, results[i].Err = buildResponse(commands[i], response)
+
+		switch {
+		case results[i].Err == nil:
+			results[i].Response, results[i].Err = c.claim(ctx, commands[i], results[i].Response)
+
+		case isRejected(results[i].Err):
+			c.discardBlob(keys[i])
+		}
+	}
+
+	if err != nil {
+		err = c.abandon(ctx, err)
+
+		for i := len(responses); i < len(results); i++ {
+			results[i].Err = err
+		}
+
+		return results, err
+	}
+
+	return results, nil
+}
@@ -0,0 +1,126 @@
+package beanstalk_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_Execute(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(nil, nil))
+
+		results, err := c.Pipeline().Execute()
+
+		require.Nil(t, err)
+		require.Empty(t, results)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("success", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"put 1 0 60 4\r\ntest\r\ndelete 1\r\ndelete 2\r\n"},
+			[]string{"INSERTED 1\r\nDELETED\r\nNOT_FOUND\r\n"},
+		))
+
+		pipeline := c.Pipeline().
+			Add(beanstalk.PutCommand{Priority: 1, TTR: 1 * time.Minute, Data: []byte("test")}).
+			Add(beanstalk.DeleteCommand{ID: 1}).
+			Add(beanstalk.DeleteCommand{ID: 2})
+
+		require.Equal(t, 3, pipeline.Len())
+
+		results, err := pipeline.Execute()
+
+		require.Nil(t, err)
+		require.Len(t, results, 3)
+		require.Equal(t, beanstalk.PutCommandResponse{ID: 1}, results[0].Response)
+		require.Nil(t, results[0].Err)
+		require.Nil(t, results[1].Err)
+		require.Equal(t, beanstalk.ErrNotFound, results[2].Err)
+
+		pipeline.Reset()
+
+		require.Equal(t, 0, pipeline.Len())
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("read failure", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"delete 1\r\ndelete 2\r\n"},
+			[]string{"DELETED\r\n"},
+		))
+
+		results, err := c.Pipeline().
+			Add(beanstalk.DeleteCommand{ID: 1}).
+			Add(beanstalk.DeleteCommand{ID: 2}).
+			Execute()
+
+		require.Equal(t, io.EOF, err)
+		require.Nil(t, results[0].Err)
+		require.Equal(t, io.EOF, results[1].Err)
+		require.NotEqual(t, int64(0), c.ClosedAt().Unix())
+	})
+
+	t.Run("large batch", func(t *testing.T) {
+		clientConn, serverConn := net.Pipe()
+
+		defer serverConn.Close()
+
+		go func() {
+			r := bufio.NewReader(serverConn)
+
+			for id := 1; ; id++ {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if strings.HasPrefix(line, "put ") {
+					if _, err = r.ReadString('\n'); err != nil {
+						return
+					}
+				}
+
+				if _, err = fmt.Fprintf(serverConn, "INSERTED %d\r\n", id); err != nil {
+					return
+				}
+			}
+		}()
+
+		c := beanstalk.NewClient(clientConn)
+
+		pipeline := c.Pipeline()
+
+		for i := 0; i < 10000; i++ {
+			pipeline.Add(beanstalk.PutCommand{TTR: 1 * time.Minute, Data: []byte("test")})
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+		defer cancel()
+
+		results, err := pipeline.ExecuteContext(ctx)
+
+		require.Nil(t, err)
+		require.Len(t, results, 10000)
+
+		for i, result := range results {
+			require.Nil(t, result.Err)
+			require.Equal(t, beanstalk.PutCommandResponse{ID: i + 1}, result.Response)
+		}
+
+		require.NoError(t, c.Close())
+	})
+}
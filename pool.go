@@ -13,12 +13,14 @@ var (
 	ErrAlreadyOpenedPool  = errors.New("beanstalk: pool: already opened")
 	ErrClosedPool         = errors.New("beanstalk: pool: closed")
 	ErrDialerNotSpecified = errors.New("beanstalk: pool: dialer not specified")
+	ErrPoolTimeout        = errors.New("beanstalk: pool: timeout")
 )
 
 type PoolOptions struct {
 	Dialer      func() (*Client, error)
 	Logger      Logger
 	Capacity    int
+	MaxActive   int
 	MaxAge      time.Duration
 	IdleTimeout time.Duration
 }
@@ -26,6 +28,8 @@ type PoolOptions struct {
 type Pool struct {
 	options   *PoolOptions
 	clients   []*Client
+	active    int
+	waiters   []chan *Client
 	triggerCh chan struct{}
 	closeCh   chan struct{}
 	closed    int32
@@ -41,6 +45,14 @@ func NewDefaultPool(options *PoolOptions) *Pool {
 		options.Capacity = 1
 	}
 
+	if options.MaxActive < 0 {
+		options.MaxActive = 0
+	}
+
+	if options.MaxActive > 0 && options.MaxActive < options.Capacity {
+		options.Capacity = options.MaxActive
+	}
+
 	if options.MaxAge < 0 {
 		options.MaxAge = 0
 	}
@@ -52,7 +64,7 @@ func NewDefaultPool(options *PoolOptions) *Pool {
 	return &Pool{
 		options:   options,
 		clients:   make([]*Client, 0, options.Capacity),
-		triggerCh: make(chan struct{}),
+		triggerCh: make(chan struct{}, 1),
 		closeCh:   make(chan struct{}),
 		closed:    1,
 	}
@@ -116,7 +128,13 @@ func (p *Pool) Close(ctx context.Context) error {
 			}
 		}
 
+		for _, waiter := range p.waiters {
+			close(waiter)
+		}
+
+		p.active -= len(p.clients)
 		p.clients = p.clients[:0]
+		p.waiters = nil
 	}()
 
 	select {
@@ -130,42 +148,90 @@ func (p *Pool) Close(ctx context.Context) error {
 }
 
 func (p *Pool) Get() (*Client, error) {
+	return p.GetContext(context.Background())
+}
+
+func (p *Pool) GetContext(ctx context.Context) (*Client, error) {
 	if p.isClosed() {
 		return nil, ErrClosedPool
 	}
 
 	for {
-		if p.Len() == 0 {
-			break
-		}
-
 		p.options.Logger.Log(DebugLogLevel, "Tries to fetch client", nil)
 
 		p.mutex.Lock()
-		client := p.clients[0]
-		p.clients = append(p.clients[:0], p.clients[1:]...)
-		p.mutex.Unlock()
 
-		if !p.checkClient(client) {
-			p.options.Logger.Log(DebugLogLevel, "Closes stale client", nil)
+		if len(p.clients) > 0 {
+			client := p.clients[0]
+			p.clients = append(p.clients[:0], p.clients[1:]...)
+			p.mutex.Unlock()
 
-			if err := client.Close(); err != nil {
-				p.options.Logger.Log(ErrorLogLevel, "Failed to close client", map[string]interface{}{"error": err})
+			if !p.checkClient(client) {
+				p.closeClient(client)
+
+				continue
 			}
 
-			continue
+			p.options.Logger.Log(DebugLogLevel, "Client was fetched", nil)
+
+			return client, nil
 		}
 
-		p.options.Logger.Log(DebugLogLevel, "Client was fetched", nil)
+		if p.options.MaxActive == 0 || p.active < p.options.MaxActive {
+			p.active++
+			p.mutex.Unlock()
 
-		return client, nil
-	}
+			p.trigger()
 
-	p.triggerCh <- struct{}{}
+			return p.dialClient()
+		}
 
-	p.options.Logger.Log(DebugLogLevel, "Gets client by factory method", nil)
+		waiter := make(chan *Client, 1)
+		p.waiters = append(p.waiters, waiter)
+		p.mutex.Unlock()
+
+		p.options.Logger.Log(DebugLogLevel, "Waits for client", nil)
+
+		select {
+		case client, ok := <-waiter:
+			if !ok {
+				return nil, ErrClosedPool
+			}
+
+			if client == nil {
+				return p.dialClient()
+			}
+
+			if !p.checkClient(client) {
+				// reuses the slot of the stale client
+				p.discardClient(client)
+
+				return p.dialClient()
+			}
+
+			p.options.Logger.Log(DebugLogLevel, "Client was handed over", nil)
+
+			return client, nil
+
+		case <-ctx.Done():
+			p.mutex.Lock()
+			removed := p.removeWaiter(waiter)
+			p.mutex.Unlock()
 
-	return p.createClient()
+			if !removed {
+				// a client or a free slot was handed over concurrently, passes it on
+				if client, ok := <-waiter; ok {
+					if client == nil {
+						p.releaseSlot()
+					} else if err := p.Put(client); err != nil {
+						p.closeClient(client)
+					}
+				}
+			}
+
+			return nil, fmt.Errorf("%w: %w", ErrPoolTimeout, ctx.Err())
+		}
+	}
 }
 
 func (p *Pool) Put(client *Client) error {
@@ -175,17 +241,34 @@ func (p *Pool) Put(client *Client) error {
 
 	p.options.Logger.Log(DebugLogLevel, "Tries to return client", nil)
 
-	if p.Len() >= p.options.Capacity || !p.checkClient(client) {
-		p.options.Logger.Log(DebugLogLevel, "Closes stale client", nil)
-
-		if err := client.Close(); err != nil {
-			p.options.Logger.Log(ErrorLogLevel, "Failed to close client", map[string]interface{}{"error": err})
-		}
+	if !p.checkClient(client) {
+		p.closeClient(client)
 
 		return nil
 	}
 
 	p.mutex.Lock()
+
+	if len(p.waiters) > 0 {
+		waiter := p.waiters[0]
+		p.waiters = append(p.waiters[:0], p.waiters[1:]...)
+		p.mutex.Unlock()
+
+		waiter <- client
+
+		p.options.Logger.Log(DebugLogLevel, "Client was handed over to waiter", nil)
+
+		return nil
+	}
+
+	if len(p.clients) >= p.options.Capacity {
+		p.mutex.Unlock()
+
+		p.closeClient(client)
+
+		return nil
+	}
+
 	p.clients = append(p.clients, client)
 	p.mutex.Unlock()
 
@@ -235,6 +318,13 @@ func (p *Pool) refillClients() {
 	}
 }
 
+func (p *Pool) trigger() {
+	select {
+	case p.triggerCh <- struct{}{}:
+	default:
+	}
+}
+
 func (p *Pool) createClient() (*Client, error) {
 	if p.options.Dialer == nil {
 		return nil, ErrDialerNotSpecified
@@ -243,30 +333,110 @@ func (p *Pool) createClient() (*Client, error) {
 	return p.options.Dialer()
 }
 
+// dialClient creates a client for an already reserved active slot.
+func (p *Pool) dialClient() (*Client, error) {
+	p.options.Logger.Log(DebugLogLevel, "Gets client by factory method", nil)
+
+	client, err := p.createClient()
+	if err != nil {
+		p.releaseSlot()
+
+		return nil, err
+	}
+
+	return client, nil
+}
+
 func (p *Pool) createAndPutClient() {
+	p.mutex.Lock()
+
+	if p.isClosed() || len(p.clients) >= p.options.Capacity || (p.options.MaxActive > 0 && p.active >= p.options.MaxActive) {
+		p.mutex.Unlock()
+
+		return
+	}
+
+	p.active++
+	p.mutex.Unlock()
+
 	client, err := p.createClient()
 	if err != nil {
 		p.options.Logger.Log(ErrorLogLevel, "Failed to create client", map[string]interface{}{"error": err})
 
+		p.releaseSlot()
+
 		return
 	}
 
 	p.options.Logger.Log(DebugLogLevel, "Client was created", nil)
 
 	p.mutex.Lock()
-	defer p.mutex.Unlock()
+
+	if len(p.waiters) > 0 {
+		waiter := p.waiters[0]
+		p.waiters = append(p.waiters[:0], p.waiters[1:]...)
+		p.mutex.Unlock()
+
+		waiter <- client
+
+		return
+	}
 
 	if p.isClosed() || len(p.clients) >= p.options.Capacity {
-		p.options.Logger.Log(DebugLogLevel, "Closes stale client", nil)
+		p.mutex.Unlock()
 
-		if err = client.Close(); err != nil {
-			p.options.Logger.Log(ErrorLogLevel, "Failed to close client", map[string]interface{}{"error": err})
-		}
+		p.closeClient(client)
 
 		return
 	}
 
 	p.clients = append(p.clients, client)
+	p.mutex.Unlock()
+}
+
+// closeClient closes a client that leaves the pool and frees its active slot.
+func (p *Pool) closeClient(client *Client) {
+	p.discardClient(client)
+	p.releaseSlot()
+}
+
+func (p *Pool) discardClient(client *Client) {
+	p.options.Logger.Log(DebugLogLevel, "Closes stale client", nil)
+
+	if err := client.Close(); err != nil {
+		p.options.Logger.Log(ErrorLogLevel, "Failed to close client", map[string]interface{}{"error": err})
+	}
+}
+
+// releaseSlot frees an active slot, the first waiter is allowed to dial a new client.
+func (p *Pool) releaseSlot() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.waiters) > 0 {
+		waiter := p.waiters[0]
+		p.waiters = append(p.waiters[:0], p.waiters[1:]...)
+
+		waiter <- nil
+
+		return
+	}
+
+	if p.active > 0 {
+		p.active--
+	}
+}
+
+func (p *Pool) removeWaiter(waiter chan *Client) bool {
+	for i, w := range p.waiters {
+		if w == waiter {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
 }
 
 func (p *Pool) checkClient(client *Client) bool {
@@ -374,3 +374,148 @@ func TestDefaultPool_Put(t *testing.T) {
 		require.Equal(t, 0, pool.Len())
 	})
 }
+
+func TestDefaultPool_GetContext(t *testing.T) {
+	t.Run("closed pool", func(t *testing.T) {
+		pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				return beanstalk.NewClient(mock.NewConn(nil, nil)), nil
+			},
+		})
+
+		client, err := pool.GetContext(context.Background())
+
+		require.Equal(t, beanstalk.ErrClosedPool, err)
+		require.Nil(t, client)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				return beanstalk.NewClient(mock.NewConn(nil, nil)), nil
+			},
+			Logger:    beanstalk.NopLogger,
+			Capacity:  1,
+			MaxActive: 1,
+		})
+
+		// opens pool
+		require.NoError(t, pool.Open(context.Background()))
+
+		// gets the only client
+		client, err := pool.GetContext(context.Background())
+
+		require.Nil(t, err)
+		require.NotNil(t, client)
+
+		// waits for client
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		defer cancel()
+
+		_, err = pool.GetContext(ctx)
+
+		require.ErrorIs(t, err, beanstalk.ErrPoolTimeout)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// puts client in pool
+		require.NoError(t, pool.Put(client))
+
+		require.Equal(t, 1, pool.Len())
+
+		// closes pool
+		require.NoError(t, pool.Close(context.Background()))
+	})
+
+	t.Run("hand over", func(t *testing.T) {
+		pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				return beanstalk.NewClient(mock.NewConn(nil, nil)), nil
+			},
+			Logger:    beanstalk.NopLogger,
+			Capacity:  1,
+			MaxActive: 1,
+		})
+
+		// opens pool
+		require.NoError(t, pool.Open(context.Background()))
+
+		// gets the only client
+		client, err := pool.GetContext(context.Background())
+
+		require.Nil(t, err)
+		require.NotNil(t, client)
+
+		// waits for client in order
+		resultCh := make(chan *beanstalk.Client, 2)
+
+		for i := 0; i < 2; i++ {
+			go func() {
+				c, err := pool.GetContext(context.Background())
+				if err != nil {
+					resultCh <- nil
+
+					return
+				}
+
+				resultCh <- c
+			}()
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		// returns client to the first waiter
+		require.NoError(t, pool.Put(client))
+
+		waited := <-resultCh
+
+		require.Same(t, client, waited)
+
+		// closes the client, the second waiter dials a new one
+		require.NoError(t, waited.Close())
+		require.NoError(t, pool.Put(waited))
+
+		waited = <-resultCh
+
+		require.NotNil(t, waited)
+		require.NotSame(t, client, waited)
+
+		// closes pool
+		require.NoError(t, pool.Close(context.Background()))
+	})
+
+	t.Run("closed while waiting", func(t *testing.T) {
+		pool := beanstalk.NewDefaultPool(&beanstalk.PoolOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				return beanstalk.NewClient(mock.NewConn(nil, nil)), nil
+			},
+			Logger:    beanstalk.NopLogger,
+			Capacity:  1,
+			MaxActive: 1,
+		})
+
+		// opens pool
+		require.NoError(t, pool.Open(context.Background()))
+
+		// gets the only client
+		client, err := pool.GetContext(context.Background())
+
+		require.Nil(t, err)
+		require.NotNil(t, client)
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			_, err := pool.GetContext(context.Background())
+
+			errCh <- err
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+
+		// closes pool
+		require.NoError(t, pool.Close(context.Background()))
+
+		require.Equal(t, beanstalk.ErrClosedPool, <-errCh)
+	})
+}
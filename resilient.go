@@ -0,0 +1,471 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrClosedClient = errors.New("beanstalk: client: closed")
+
+type ResilientClientOptions struct {
+	Dialer     func() (*Client, error)
+	Logger     Logger
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// limits the replays of a command after reconnects, zero means no limit
+	MaxRetries int
+}
+
+// ResilientClient redials on connection failures and restores the used tube and the
+// watch list on the new connection. Commands that are safe to repeat are replayed,
+// others return the connection error. It is safe for concurrent use.
+type ResilientClient struct {
+	options *ResilientClientOptions
+	client  *Client
+	used    string
+	watched []string
+	closed  int32
+	// is closed by Close to stop waiting for the next dial
+	done  chan struct{}
+	mutex sync.Mutex
+}
+
+func NewResilientClient(options *ResilientClientOptions) *ResilientClient {
+	if options.Logger == nil {
+		options.Logger = NopLogger
+	}
+
+	if options.MinBackoff <= 0 {
+		options.MinBackoff = 100 * time.Millisecond
+	}
+
+	if options.MaxBackoff < options.MinBackoff {
+		options.MaxBackoff = 10 * time.Second
+
+		if options.MaxBackoff < options.MinBackoff {
+			options.MaxBackoff = options.MinBackoff
+		}
+	}
+
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+
+	return &ResilientClient{
+		options: options,
+		used:    "default",
+		watched: []string{"default"},
+		done:    make(chan struct{}),
+	}
+}
+
+func (c *ResilientClient) Close() error {
+	if !atomic.CompareAndSwapInt32(&c.closed, 0, 1) {
+		return ErrClosedClient
+	}
+
+	close(c.done)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.client == nil {
+		return nil
+	}
+
+	err := c.client.Close()
+	c.client = nil
+
+	return err
+}
+
+func (c *ResilientClient) ExecuteCommand(command Command) (CommandResponse, error) {
+	return c.ExecuteCommandContext(context.Background(), command)
+}
+
+func (c *ResilientClient) ExecuteCommandContext(ctx context.Context, command Command) (CommandResponse, error) {
+	for retries := 0; ; retries++ {
+		client, err := c.connect(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := client.ExecuteCommandContext(ctx, command)
+		if err == nil {
+			c.track(command, response)
+
+			return response, nil
+		}
+
+		// protocol errors leave the connection intact
+		if client.ClosedAt().Unix() == 0 {
+			return nil, err
+		}
+
+		// the client is closed on cancellation as well, when the response is abandoned
+		c.disconnect(client, err)
+
+		if ctx.Err() != nil || !isReplayableCommand(command) || (c.options.MaxRetries > 0 && retries >= c.options.MaxRetries) {
+			return nil, err
+		}
+
+		c.options.Logger.Log(InfoLogLevel, "Replays command", map[string]interface{}{"command": command.CommandLine()})
+	}
+}
+
+func (c *ResilientClient) Put(priority uint32, delay, ttr time.Duration, data []byte) (int, error) {
+	return c.PutContext(context.Background(), priority, delay, ttr, data)
+}
+
+func (c *ResilientClient) PutContext(ctx context.Context, priority uint32, delay, ttr time.Duration, data []byte) (int, error) {
+	return executePut(ctx, c, priority, delay, ttr, data)
+}
+
+func (c *ResilientClient) Use(tube string) (string, error) {
+	return c.UseContext(context.Background(), tube)
+}
+
+func (c *ResilientClient) UseContext(ctx context.Context, tube string) (string, error) {
+	return executeUse(ctx, c, tube)
+}
+
+func (c *ResilientClient) Reserve() (*Job, error) {
+	return c.ReserveContext(context.Background())
+}
+
+func (c *ResilientClient) ReserveContext(ctx context.Context) (*Job, error) {
+	return executeReserve(ctx, c)
+}
+
+func (c *ResilientClient) ReserveWithTimeout(timeout time.Duration) (*Job, error) {
+	return c.ReserveWithTimeoutContext(context.Background(), timeout)
+}
+
+func (c *ResilientClient) ReserveWithTimeoutContext(ctx context.Context, timeout time.Duration) (*Job, error) {
+	return executeReserveWithTimeout(ctx, c, timeout)
+}
+
+func (c *ResilientClient) ReserveJob(id int) (*Job, error) {
+	return c.ReserveJobContext(context.Background(), id)
+}
+
+func (c *ResilientClient) ReserveJobContext(ctx context.Context, id int) (*Job, error) {
+	return executeReserveJob(ctx, c, id)
+}
+
+func (c *ResilientClient) Delete(id int) error {
+	return c.DeleteContext(context.Background(), id)
+}
+
+func (c *ResilientClient) DeleteContext(ctx context.Context, id int) error {
+	return executeDelete(ctx, c, id)
+}
+
+func (c *ResilientClient) Release(id int, priority uint32, delay time.Duration) error {
+	return c.ReleaseContext(context.Background(), id, priority, delay)
+}
+
+func (c *ResilientClient) ReleaseContext(ctx context.Context, id int, priority uint32, delay time.Duration) error {
+	return executeRelease(ctx, c, id, priority, delay)
+}
+
+func (c *ResilientClient) Bury(id int, priority uint32) error {
+	return c.BuryContext(context.Background(), id, priority)
+}
+
+func (c *ResilientClient) BuryContext(ctx context.Context, id int, priority uint32) error {
+	return executeBury(ctx, c, id, priority)
+}
+
+func (c *ResilientClient) Touch(id int) error {
+	return c.TouchContext(context.Background(), id)
+}
+
+func (c *ResilientClient) TouchContext(ctx context.Context, id int) error {
+	return executeTouch(ctx, c, id)
+}
+
+func (c *ResilientClient) Watch(tube string) (int, error) {
+	return c.WatchContext(context.Background(), tube)
+}
+
+func (c *ResilientClient) WatchContext(ctx context.Context, tube string) (int, error) {
+	return executeWatch(ctx, c, tube)
+}
+
+func (c *ResilientClient) Ignore(tube string) (int, error) {
+	return c.IgnoreContext(context.Background(), tube)
+}
+
+func (c *ResilientClient) IgnoreContext(ctx context.Context, tube string) (int, error) {
+	return executeIgnore(ctx, c, tube)
+}
+
+func (c *ResilientClient) Peek(id int) (*Job, error) {
+	return c.PeekContext(context.Background(), id)
+}
+
+func (c *ResilientClient) PeekContext(ctx context.Context, id int) (*Job, error) {
+	return executePeek(ctx, c, id)
+}
+
+func (c *ResilientClient) PeekReady() (*Job, error) {
+	return c.PeekReadyContext(context.Background())
+}
+
+func (c *ResilientClient) PeekReadyContext(ctx context.Context) (*Job, error) {
+	return executePeekReady(ctx, c)
+}
+
+func (c *ResilientClient) PeekDelayed() (*Job, error) {
+	return c.PeekDelayedContext(context.Background())
+}
+
+func (c *ResilientClient) PeekDelayedContext(ctx context.Context) (*Job, error) {
+	return executePeekDelayed(ctx, c)
+}
+
+func (c *ResilientClient) PeekBuried() (*Job, error) {
+	return c.PeekBuriedContext(context.Background())
+}
+
+func (c *ResilientClient) PeekBuriedContext(ctx context.Context) (*Job, error) {
+	return executePeekBuried(ctx, c)
+}
+
+func (c *ResilientClient) Kick(bound int) (int, error) {
+	return c.KickContext(context.Background(), bound)
+}
+
+func (c *ResilientClient) KickContext(ctx context.Context, bound int) (int, error) {
+	return executeKick(ctx, c, bound)
+}
+
+func (c *ResilientClient) KickJob(id int) error {
+	return c.KickJobContext(context.Background(), id)
+}
+
+func (c *ResilientClient) KickJobContext(ctx context.Context, id int) error {
+	return executeKickJob(ctx, c, id)
+}
+
+func (c *ResilientClient) StatsJob(id int) (*StatsJob, error) {
+	return c.StatsJobContext(context.Background(), id)
+}
+
+func (c *ResilientClient) StatsJobContext(ctx context.Context, id int) (*StatsJob, error) {
+	return executeStatsJob(ctx, c, id)
+}
+
+func (c *ResilientClient) StatsTube(tube string) (*StatsTube, error) {
+	return c.StatsTubeContext(context.Background(), tube)
+}
+
+func (c *ResilientClient) StatsTubeContext(ctx context.Context, tube string) (*StatsTube, error) {
+	return executeStatsTube(ctx, c, tube)
+}
+
+func (c *ResilientClient) Stats() (*Stats, error) {
+	return c.StatsContext(context.Background())
+}
+
+func (c *ResilientClient) StatsContext(ctx context.Context) (*Stats, error) {
+	return executeStats(ctx, c)
+}
+
+func (c *ResilientClient) ListTubes() ([]string, error) {
+	return c.ListTubesContext(context.Background())
+}
+
+func (c *ResilientClient) ListTubesContext(ctx context.Context) ([]string, error) {
+	return executeListTubes(ctx, c)
+}
+
+func (c *ResilientClient) ListTubeUsed() (string, error) {
+	return c.ListTubeUsedContext(context.Background())
+}
+
+func (c *ResilientClient) ListTubeUsedContext(ctx context.Context) (string, error) {
+	return executeListTubeUsed(ctx, c)
+}
+
+func (c *ResilientClient) ListTubesWatched() ([]string, error) {
+	return c.ListTubesWatchedContext(context.Background())
+}
+
+func (c *ResilientClient) ListTubesWatchedContext(ctx context.Context) ([]string, error) {
+	return executeListTubesWatched(ctx, c)
+}
+
+func (c *ResilientClient) PauseTube(tube string, delay time.Duration) error {
+	return c.PauseTubeContext(context.Background(), tube, delay)
+}
+
+func (c *ResilientClient) PauseTubeContext(ctx context.Context, tube string, delay time.Duration) error {
+	return executePauseTube(ctx, c, tube, delay)
+}
+
+func (c *ResilientClient) connect(ctx context.Context) (*Client, error) {
+	backoff := c.options.MinBackoff
+
+	for attempt := 1; ; attempt++ {
+		client, err := c.dial(ctx, attempt)
+		if err == nil || err == ErrClosedClient || err == ErrDialerNotSpecified {
+			return client, err
+		}
+
+		c.options.Logger.Log(WarningLogLevel, "Failed to connect", map[string]interface{}{"attempt": attempt, "error": err})
+
+		// waits without the mutex, so Close is not blocked
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return nil, ctx.Err()
+
+		case <-c.done:
+			timer.Stop()
+
+			return nil, ErrClosedClient
+
+		case <-timer.C:
+		}
+
+		if backoff *= 2; backoff > c.options.MaxBackoff {
+			backoff = c.options.MaxBackoff
+		}
+	}
+}
+
+// dial returns the current client or dials a new one and restores its tubes.
+func (c *ResilientClient) dial(ctx context.Context, attempt int) (*Client, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return nil, ErrClosedClient
+	}
+
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	if c.options.Dialer == nil {
+		return nil, ErrDialerNotSpecified
+	}
+
+	client, err := c.options.Dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.restore(ctx, client); err != nil {
+		_ = client.Close()
+
+		return nil, err
+	}
+
+	c.client = client
+
+	if attempt > 1 {
+		c.options.Logger.Log(InfoLogLevel, "Client was reconnected", map[string]interface{}{"attempts": attempt})
+	}
+
+	return client, nil
+}
+
+// restore re-issues use, watch and ignore commands, since a new connection uses
+// and watches the default tube only.
+func (c *ResilientClient) restore(ctx context.Context, client *Client) error {
+	if c.used != "default" {
+		if _, err := client.UseContext(ctx, c.used); err != nil {
+			return err
+		}
+	}
+
+	ignoreDefault := true
+
+	for _, tube := range c.watched {
+		if tube == "default" {
+			ignoreDefault = false
+
+			continue
+		}
+
+		if _, err := client.WatchContext(ctx, tube); err != nil {
+			return err
+		}
+	}
+
+	if ignoreDefault {
+		if _, err := client.IgnoreContext(ctx, "default"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ResilientClient) disconnect(client *Client, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.client != client {
+		return
+	}
+
+	c.client = nil
+
+	c.options.Logger.Log(WarningLogLevel, "Connection was lost", map[string]interface{}{"error": err})
+}
+
+func (c *ResilientClient) track(command Command, response CommandResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch command := command.(type) {
+	case UseCommand:
+		c.used = response.(UseCommandResponse).Tube
+
+	case WatchCommand:
+		for _, tube := range c.watched {
+			if tube == command.Tube {
+				return
+			}
+		}
+
+		c.watched = append(c.watched, command.Tube)
+
+	case IgnoreCommand:
+		for i, tube := range c.watched {
+			if tube == command.Tube {
+				c.watched = append(c.watched[:i], c.watched[i+1:]...)
+
+				return
+			}
+		}
+	}
+}
+
+// isReplayableCommand reports whether a command may be repeated on a new connection
+// when its response was lost. Reservations of the lost connection are released by
+// the server, so reserving again is safe, unlike put, delete, release, bury, touch and kick.
+func isReplayableCommand(command Command) bool {
+	switch command.(type) {
+	case UseCommand, WatchCommand, IgnoreCommand,
+		ReserveCommand, ReserveWithTimeoutCommand, ReserveJobCommand,
+		PeekCommand, PeekReadyCommand, PeekDelayedCommand, PeekBuriedCommand,
+		StatsCommand, StatsJobCommand, StatsTubeCommand,
+		ListTubesCommand, ListTubeUsedCommand, ListTubesWatchedCommand,
+		PauseTubeCommand:
+		return true
+
+	default:
+		return false
+	}
+}
@@ -0,0 +1,175 @@
+package beanstalk_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/beanstalktest"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceDialer returns a dialer that hands out the given connections in order.
+func sequenceDialer(conns ...io.ReadWriteCloser) (func() (*beanstalk.Client, error), *int) {
+	dials := 0
+
+	return func() (*beanstalk.Client, error) {
+		dials++
+
+		if len(conns) == 0 {
+			return nil, errors.New("test")
+		}
+
+		conn := conns[0]
+		conns = conns[1:]
+
+		if conn == nil {
+			return nil, errors.New("test")
+		}
+
+		return beanstalk.NewClient(conn), nil
+	}, &dials
+}
+
+func TestResilientClient(t *testing.T) {
+	t.Run("restores state", func(t *testing.T) {
+		dialer, dials := sequenceDialer(
+			mock.NewConn(
+				[]string{"use test\r\n", "watch test\r\n", "ignore default\r\n", "reserve-with-timeout 5\r\n"},
+				[]string{"USING test\r\n", "WATCHING 2\r\n", "WATCHING 1\r\n"},
+			),
+			nil,
+			mock.NewConn(
+				[]string{"use test\r\n", "watch test\r\n", "ignore default\r\n", "reserve-with-timeout 5\r\n"},
+				[]string{"USING test\r\n", "WATCHING 2\r\n", "WATCHING 1\r\n", "RESERVED 1 4\r\ntest\r\n"},
+			),
+		)
+
+		c := beanstalk.NewResilientClient(&beanstalk.ResilientClientOptions{Dialer: dialer, MinBackoff: 1 * time.Millisecond})
+
+		tube, err := c.Use("test")
+
+		require.Nil(t, err)
+		require.Equal(t, "test", tube)
+
+		_, err = c.Watch("test")
+
+		require.Nil(t, err)
+
+		_, err = c.Ignore("default")
+
+		require.Nil(t, err)
+
+		job, err := c.ReserveWithTimeout(5 * time.Second)
+
+		require.Nil(t, err)
+		require.Equal(t, &beanstalk.Job{ID: 1, Data: []byte("test")}, job)
+		require.Equal(t, 3, *dials)
+
+		require.NoError(t, c.Close())
+		require.Equal(t, beanstalk.ErrClosedClient, c.Close())
+	})
+
+	t.Run("no replay", func(t *testing.T) {
+		dialer, dials := sequenceDialer(
+			mock.NewConn([]string{"put 1 0 60 4\r\ntest\r\n"}, nil),
+		)
+
+		c := beanstalk.NewResilientClient(&beanstalk.ResilientClientOptions{Dialer: dialer})
+
+		_, err := c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.Equal(t, io.EOF, err)
+		require.Equal(t, 1, *dials)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("max retries", func(t *testing.T) {
+		dialer, dials := sequenceDialer(
+			mock.NewConn([]string{"list-tube-used\r\n"}, nil),
+			mock.NewConn([]string{"list-tube-used\r\n"}, nil),
+			mock.NewConn([]string{"list-tube-used\r\n"}, nil),
+		)
+
+		c := beanstalk.NewResilientClient(&beanstalk.ResilientClientOptions{Dialer: dialer, MaxRetries: 1})
+
+		_, err := c.ListTubeUsed()
+
+		require.Equal(t, io.EOF, err)
+		require.Equal(t, 2, *dials)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		dialer, _ := sequenceDialer()
+
+		c := beanstalk.NewResilientClient(&beanstalk.ResilientClientOptions{Dialer: dialer, MinBackoff: 1 * time.Hour})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+
+		defer cancel()
+
+		_, err := c.ListTubeUsedContext(ctx)
+
+		require.Equal(t, context.DeadlineExceeded, err)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("close while redialing", func(t *testing.T) {
+		dialed := make(chan struct{}, 1)
+
+		c := beanstalk.NewResilientClient(&beanstalk.ResilientClientOptions{
+			Dialer: func() (*beanstalk.Client, error) {
+				select {
+				case dialed <- struct{}{}:
+				default:
+				}
+
+				return nil, errors.New("test")
+			},
+			MinBackoff: 1 * time.Hour,
+		})
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			_, err := c.Stats()
+
+			errCh <- err
+		}()
+
+		<-dialed
+
+		require.NoError(t, c.Close())
+		require.Equal(t, beanstalk.ErrClosedClient, <-errCh)
+	})
+
+	t.Run("cancelled reserve", func(t *testing.T) {
+		s := beanstalktest.NewServer()
+
+		defer s.Close()
+
+		c := beanstalk.NewResilientClient(&beanstalk.ResilientClientOptions{Dialer: beanstalk.NewDialer(s.Addr, nil)})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		defer cancel()
+
+		_, err := c.ReserveContext(ctx)
+
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		// the abandoned connection is replaced
+		_, err = c.Put(1, 0, 1*time.Minute, []byte("test"))
+
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+	})
+}
@@ -0,0 +1,96 @@
+package beanstalk
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy decides what happens to a failed job. Backoff receives the attempt
+// number, starting at 1, and returns the release delay, or false when the job
+// should be buried.
+type RetryPolicy interface {
+	Backoff(attempt int) (time.Duration, bool)
+}
+
+type RetryPolicyFunc func(attempt int) (time.Duration, bool)
+
+func (f RetryPolicyFunc) Backoff(attempt int) (time.Duration, bool) {
+	return f(attempt)
+}
+
+type ConstantRetryPolicy struct {
+	Delay time.Duration
+	// zero means no limit
+	MaxAttempts int
+}
+
+func (p ConstantRetryPolicy) Backoff(attempt int) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	return p.Delay, true
+}
+
+type ExponentialRetryPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	// defaults to 2, 1 keeps the delay constant and smaller values are raised to 1, so the
+	// delay never shrinks
+	Multiplier float64
+	// is the fraction of the delay, between 0 and 1, that is randomized
+	Jitter float64
+	// zero means no limit
+	MaxAttempts int
+}
+
+func (p ExponentialRetryPolicy) Backoff(attempt int) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, false
+	}
+
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	} else if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return time.Duration(delay), true
+}
+
+// Retry releases a reserved job with the delay given by the policy for its
+// attempt number, taken from the reserves counter of the job, or buries the
+// job once the policy gives up. It reports whether the job was buried.
+func Retry(ctx context.Context, client *Client, id int, policy RetryPolicy) (bool, error) {
+	stats, err := client.StatsJobContext(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	return retry(ctx, client, stats, policy)
+}
+
+func retry(ctx context.Context, client *Client, stats *StatsJob, policy RetryPolicy) (bool, error) {
+	delay, ok := policy.Backoff(stats.Reserves)
+	if !ok {
+		return true, client.BuryContext(ctx, stats.ID, uint32(stats.Priority))
+	}
+
+	return false, client.ReleaseContext(ctx, stats.ID, uint32(stats.Priority), delay)
+}
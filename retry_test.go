@@ -0,0 +1,144 @@
+package beanstalk_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConstantRetryPolicy_Backoff(t *testing.T) {
+	policy := beanstalk.ConstantRetryPolicy{Delay: 5 * time.Second, MaxAttempts: 3}
+
+	for attempt := 1; attempt < 3; attempt++ {
+		delay, ok := policy.Backoff(attempt)
+
+		require.True(t, ok)
+		require.Equal(t, 5*time.Second, delay)
+	}
+
+	_, ok := policy.Backoff(3)
+
+	require.False(t, ok)
+
+	_, ok = beanstalk.ConstantRetryPolicy{}.Backoff(1000)
+
+	require.True(t, ok)
+}
+
+func TestExponentialRetryPolicy_Backoff(t *testing.T) {
+	t.Run("without jitter", func(t *testing.T) {
+		policy := beanstalk.ExponentialRetryPolicy{
+			InitialDelay: 1 * time.Second,
+			MaxDelay:     10 * time.Second,
+			MaxAttempts:  6,
+		}
+
+		testCases := []struct {
+			attempt  int
+			expected time.Duration
+		}{
+			{1, 1 * time.Second},
+			{2, 2 * time.Second},
+			{3, 4 * time.Second},
+			{4, 8 * time.Second},
+			{5, 10 * time.Second},
+		}
+
+		for _, testCase := range testCases {
+			delay, ok := policy.Backoff(testCase.attempt)
+
+			require.True(t, ok)
+			require.Equal(t, testCase.expected, delay)
+		}
+
+		_, ok := policy.Backoff(6)
+
+		require.False(t, ok)
+	})
+
+	t.Run("multiplier", func(t *testing.T) {
+		for _, multiplier := range []float64{1, 0.5} {
+			delay, ok := beanstalk.ExponentialRetryPolicy{InitialDelay: 1 * time.Second, Multiplier: multiplier}.Backoff(3)
+
+			require.True(t, ok)
+			require.Equal(t, 1*time.Second, delay)
+		}
+	})
+
+	t.Run("with jitter", func(t *testing.T) {
+		policy := beanstalk.ExponentialRetryPolicy{
+			InitialDelay: 1 * time.Second,
+			Multiplier:   3,
+			Jitter:       0.5,
+		}
+
+		for i := 0; i < 100; i++ {
+			delay, ok := policy.Backoff(3)
+
+			require.True(t, ok)
+			require.GreaterOrEqual(t, delay, 4500*time.Millisecond)
+			require.LessOrEqual(t, delay, 9*time.Second)
+		}
+	})
+}
+
+func TestRetryPolicyFunc_Backoff(t *testing.T) {
+	policy := beanstalk.RetryPolicyFunc(func(attempt int) (time.Duration, bool) {
+		return time.Duration(attempt) * time.Minute, attempt < 2
+	})
+
+	delay, ok := policy.Backoff(1)
+
+	require.True(t, ok)
+	require.Equal(t, 1*time.Minute, delay)
+
+	_, ok = policy.Backoff(2)
+
+	require.False(t, ok)
+}
+
+func TestRetry(t *testing.T) {
+	statsJob := "OK 41\r\n---\nid: 1\ntube: test\npri: 10\nreserves: 2\n\r\n"
+
+	t.Run("release", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"stats-job 1\r\n", "release 1 10 4\r\n"},
+			[]string{statsJob, "RELEASED\r\n"},
+		))
+
+		buried, err := beanstalk.Retry(context.Background(), c, 1, beanstalk.ExponentialRetryPolicy{InitialDelay: 2 * time.Second, MaxAttempts: 3})
+
+		require.Nil(t, err)
+		require.False(t, buried)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("bury", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn(
+			[]string{"stats-job 1\r\n", "bury 1 10\r\n"},
+			[]string{statsJob, "BURIED\r\n"},
+		))
+
+		buried, err := beanstalk.Retry(context.Background(), c, 1, beanstalk.ConstantRetryPolicy{MaxAttempts: 2})
+
+		require.Nil(t, err)
+		require.True(t, buried)
+
+		require.NoError(t, c.Close())
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		c := beanstalk.NewClient(mock.NewConn([]string{"stats-job 1\r\n"}, []string{"NOT_FOUND\r\n"}))
+
+		_, err := beanstalk.Retry(context.Background(), c, 1, beanstalk.ConstantRetryPolicy{})
+
+		require.Equal(t, beanstalk.ErrNotFound, err)
+
+		require.NoError(t, c.Close())
+	})
+}
@@ -0,0 +1,412 @@
+package beanstalk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrAlreadyStartedWorker = errors.New("beanstalk: worker: already started")
+	ErrStoppedWorker        = errors.New("beanstalk: worker: stopped")
+	ErrTubesNotSpecified    = errors.New("beanstalk: worker: tubes not specified")
+)
+
+type Handler interface {
+	ServeJob(ctx context.Context, job *Job) error
+}
+
+type HandlerFunc func(ctx context.Context, job *Job) error
+
+func (f HandlerFunc) ServeJob(ctx context.Context, job *Job) error {
+	return f(ctx, job)
+}
+
+// TubeLister is implemented by handlers that know which tubes they serve, e.g. ServeMux.
+// The worker watches the listed tubes when WorkerOptions.Tubes is empty.
+type TubeLister interface {
+	Tubes(ctx context.Context, client *Client) ([]string, error)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as not retryable, the job is buried instead of being released.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var target permanentError
+
+	return errors.As(err, &target)
+}
+
+type WorkerOptions struct {
+	Dialer         func() (*Client, error)
+	Logger         Logger
+	Tubes          []string
+	Concurrency    int
+	ReserveTimeout time.Duration
+	ReleaseDelay   time.Duration
+	RetryPolicy    RetryPolicy
+	ReconnectDelay time.Duration
+	KeepAlive      bool
+}
+
+type Worker struct {
+	options    *WorkerOptions
+	handler    Handler
+	state      int32
+	stopCtx    context.Context
+	stop       context.CancelFunc
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+func NewWorker(handler Handler, options *WorkerOptions) *Worker {
+	if options.Logger == nil {
+		options.Logger = NopLogger
+	}
+
+	if _, ok := handler.(TubeLister); !ok && len(options.Tubes) == 0 {
+		options.Tubes = []string{"default"}
+	}
+
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+
+	if options.ReserveTimeout <= 0 {
+		options.ReserveTimeout = 5 * time.Second
+	}
+
+	if options.ReleaseDelay < 0 {
+		options.ReleaseDelay = 0
+	}
+
+	if options.RetryPolicy == nil {
+		options.RetryPolicy = ConstantRetryPolicy{Delay: options.ReleaseDelay}
+	}
+
+	if options.ReconnectDelay <= 0 {
+		options.ReconnectDelay = 1 * time.Second
+	}
+
+	stopCtx, stop := context.WithCancel(context.Background())
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+
+	return &Worker{
+		options:    options,
+		handler:    handler,
+		stopCtx:    stopCtx,
+		stop:       stop,
+		jobCtx:     jobCtx,
+		cancelJobs: cancelJobs,
+	}
+}
+
+func (w *Worker) Start() error {
+	if !atomic.CompareAndSwapInt32(&w.state, 0, 1) {
+		if atomic.LoadInt32(&w.state) == 1 {
+			return ErrAlreadyStartedWorker
+		}
+
+		return ErrStoppedWorker
+	}
+
+	for i := 0; i < w.options.Concurrency; i++ {
+		w.wg.Add(1)
+
+		go w.serve()
+	}
+
+	w.options.Logger.Log(InfoLogLevel, "Worker was started", map[string]interface{}{"concurrency": w.options.Concurrency})
+
+	return nil
+}
+
+// Shutdown stops reserving new jobs and waits for in-flight jobs. When ctx is done
+// before they finish, the contexts passed to handlers are cancelled.
+func (w *Worker) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&w.state, 1, 2) {
+		return ErrStoppedWorker
+	}
+
+	w.stop()
+
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+
+		w.wg.Wait()
+	}()
+
+	select {
+	case <-doneCh:
+		w.cancelJobs()
+
+		w.options.Logger.Log(InfoLogLevel, "Worker was stopped", nil)
+
+		return nil
+	case <-ctx.Done():
+		w.cancelJobs()
+
+		return fmt.Errorf("beanstalk: worker: %v", ctx.Err())
+	}
+}
+
+func (w *Worker) serve() {
+	defer w.wg.Done()
+
+	var (
+		client *Client
+		tubes  []string
+	)
+
+	defer func() {
+		if client != nil {
+			w.closeClient(client)
+		}
+	}()
+
+	for w.stopCtx.Err() == nil {
+		if client == nil {
+			c, t, err := w.connect()
+			if err != nil {
+				w.options.Logger.Log(ErrorLogLevel, "Failed to connect", map[string]interface{}{"error": err})
+
+				w.sleep(w.options.ReconnectDelay)
+
+				continue
+			}
+
+			client, tubes = c, t
+		}
+
+		job, err := client.ReserveWithTimeoutContext(w.stopCtx, w.options.ReserveTimeout)
+		if err != nil {
+			if w.stopCtx.Err() != nil {
+				return
+			}
+
+			if !errors.Is(err, ErrTimedOut) && !errors.Is(err, ErrDeadlineSoon) {
+				w.options.Logger.Log(ErrorLogLevel, "Failed to reserve job", map[string]interface{}{"error": err})
+			}
+
+			var blobErr *BlobError
+			if errors.As(err, &blobErr) {
+				w.fail(client, blobErr.ID)
+			}
+
+			if client.ClosedAt().Unix() > 0 {
+				client = nil
+			}
+
+			continue
+		}
+
+		var stats *StatsJob
+
+		if len(tubes) == 1 && !w.options.KeepAlive {
+			job.Tube = tubes[0]
+		} else {
+			if stats, err = client.StatsJobContext(w.jobCtx, job.ID); err != nil {
+				w.options.Logger.Log(ErrorLogLevel, "Failed to fetch job stats", map[string]interface{}{"id": job.ID, "error": err})
+
+				// the server releases the reservation once the connection is closed
+				w.closeClient(client)
+				client = nil
+
+				continue
+			}
+
+			job.Tube = stats.Tube
+		}
+
+		w.handle(client, job, stats)
+
+		if client.ClosedAt().Unix() > 0 {
+			client = nil
+		}
+	}
+}
+
+func (w *Worker) connect() (*Client, []string, error) {
+	if w.options.Dialer == nil {
+		return nil, nil, ErrDialerNotSpecified
+	}
+
+	client, err := w.options.Dialer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tubes := w.options.Tubes
+	if lister, ok := w.handler.(TubeLister); ok && len(tubes) == 0 {
+		if tubes, err = lister.Tubes(w.stopCtx, client); err != nil {
+			w.closeClient(client)
+
+			return nil, nil, err
+		}
+	}
+
+	if len(tubes) == 0 {
+		w.closeClient(client)
+
+		return nil, nil, ErrTubesNotSpecified
+	}
+
+	if err = watchTubes(w.stopCtx, client, tubes); err != nil {
+		w.closeClient(client)
+
+		return nil, nil, err
+	}
+
+	return client, tubes, nil
+}
+
+func (w *Worker) handle(client *Client, job *Job, stats *StatsJob) {
+	ctx := w.jobCtx
+
+	var lease *Lease
+
+	if w.options.KeepAlive {
+		lease = newLease(w.jobCtx, client, stats)
+		ctx = lease.Context()
+	}
+
+	err := w.serveJob(ctx, job)
+
+	if lease != nil {
+		lease.Stop()
+
+		if lErr := lease.Err(); lErr != nil {
+			w.options.Logger.Log(WarningLogLevel, "Lease was lost", map[string]interface{}{"id": job.ID, "error": lErr})
+
+			return
+		}
+	}
+
+	if err == nil {
+		if err = client.DeleteContext(w.jobCtx, job.ID); err != nil {
+			w.options.Logger.Log(ErrorLogLevel, "Failed to delete job", map[string]interface{}{"id": job.ID, "error": err})
+		}
+
+		return
+	}
+
+	w.options.Logger.Log(WarningLogLevel, "Failed to handle job", map[string]interface{}{"id": job.ID, "error": err})
+
+	if stats == nil {
+		var sErr error
+
+		if stats, sErr = client.StatsJobContext(w.jobCtx, job.ID); sErr != nil {
+			w.options.Logger.Log(ErrorLogLevel, "Failed to fetch job stats", map[string]interface{}{"id": job.ID, "error": sErr})
+
+			return
+		}
+	}
+
+	if IsPermanent(err) {
+		if err = client.BuryContext(w.jobCtx, job.ID, uint32(stats.Priority)); err != nil {
+			w.options.Logger.Log(ErrorLogLevel, "Failed to bury job", map[string]interface{}{"id": job.ID, "error": err})
+		}
+
+		return
+	}
+
+	buried, err := retry(w.jobCtx, client, stats, w.options.RetryPolicy)
+	if err != nil {
+		w.options.Logger.Log(ErrorLogLevel, "Failed to retry job", map[string]interface{}{"id": job.ID, "error": err})
+
+		return
+	}
+
+	if buried {
+		w.options.Logger.Log(WarningLogLevel, "Job was buried after attempts", map[string]interface{}{"id": job.ID, "attempts": stats.Reserves})
+	}
+}
+
+// fail releases or buries a reserved job that cannot be served, following the retry policy.
+func (w *Worker) fail(client *Client, id int) {
+	stats, err := client.StatsJobContext(w.jobCtx, id)
+	if err == nil {
+		_, err = retry(w.jobCtx, client, stats, w.options.RetryPolicy)
+	}
+
+	if err != nil {
+		w.options.Logger.Log(ErrorLogLevel, "Failed to retry job", map[string]interface{}{"id": id, "error": err})
+	}
+}
+
+func (w *Worker) serveJob(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			w.options.Logger.Log(ErrorLogLevel, "Recovered from panic in handler", map[string]interface{}{"id": job.ID, "panic": r, "stack": string(debug.Stack())})
+
+			err = fmt.Errorf("beanstalk: worker: panic: %v", r)
+		}
+	}()
+
+	return w.handler.ServeJob(ctx, job)
+}
+
+func (w *Worker) sleep(d time.Duration) {
+	timer := time.NewTimer(d)
+
+	defer timer.Stop()
+
+	select {
+	case <-w.stopCtx.Done():
+	case <-timer.C:
+	}
+}
+
+func (w *Worker) closeClient(client *Client) {
+	if err := client.Close(); err != nil {
+		w.options.Logger.Log(ErrorLogLevel, "Failed to close client", map[string]interface{}{"error": err})
+	}
+}
+
+// watchTubes makes the client watch exactly the given tubes.
+func watchTubes(ctx context.Context, client *Client, tubes []string) error {
+	ignoreDefault := true
+
+	for _, tube := range tubes {
+		if tube == "default" {
+			ignoreDefault = false
+		}
+
+		if _, err := client.WatchContext(ctx, tube); err != nil {
+			return err
+		}
+	}
+
+	if ignoreDefault {
+		if _, err := client.IgnoreContext(ctx, "default"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
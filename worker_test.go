@@ -0,0 +1,210 @@
+package beanstalk_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/artiifact/go-beanstalk"
+	"github.com/artiifact/go-beanstalk/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermanent(t *testing.T) {
+	err := errors.New("test")
+
+	require.Nil(t, beanstalk.Permanent(nil))
+	require.False(t, beanstalk.IsPermanent(err))
+	require.True(t, beanstalk.IsPermanent(beanstalk.Permanent(err)))
+	require.ErrorIs(t, beanstalk.Permanent(err), err)
+	require.Equal(t, "test", beanstalk.Permanent(err).Error())
+}
+
+func TestWorker(t *testing.T) {
+	statsJob := "OK 148\r\n" +
+		"---\n" +
+		"id: 1\n" +
+		"tube: test\n" +
+		"state: reserved\n" +
+		"pri: 100\n" +
+		"age: 12\n" +
+		"delay: 0\n" +
+		"ttr: 60\n" +
+		"time-left: 10\n" +
+		"file: 0\n" +
+		"reserves: 1\n" +
+		"timeouts: 0\n" +
+		"releases: 0\n" +
+		"buries: 0\n" +
+		"kicks: 0\n" +
+		"\r\n"
+
+	testCases := []struct {
+		name    string
+		handler beanstalk.HandlerFunc
+		in      []string
+		out     []string
+	}{
+		{
+			name: "delete",
+			handler: func(ctx context.Context, job *beanstalk.Job) error {
+				return nil
+			},
+			in:  []string{"delete 1\r\n"},
+			out: []string{"DELETED\r\n"},
+		},
+		{
+			name: "release",
+			handler: func(ctx context.Context, job *beanstalk.Job) error {
+				return errors.New("test")
+			},
+			in:  []string{"stats-job 1\r\n", "release 1 100 5\r\n"},
+			out: []string{statsJob, "RELEASED\r\n"},
+		},
+		{
+			name: "bury",
+			handler: func(ctx context.Context, job *beanstalk.Job) error {
+				return beanstalk.Permanent(errors.New("test"))
+			},
+			in:  []string{"stats-job 1\r\n", "bury 1 100\r\n"},
+			out: []string{statsJob, "BURIED\r\n"},
+		},
+		{
+			name: "panic",
+			handler: func(ctx context.Context, job *beanstalk.Job) error {
+				panic("test")
+			},
+			in:  []string{"stats-job 1\r\n", "release 1 100 5\r\n"},
+			out: []string{statsJob, "RELEASED\r\n"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			var dials int32
+
+			jobCh := make(chan *beanstalk.Job, 1)
+			redialCh := make(chan struct{})
+
+			worker := beanstalk.NewWorker(
+				beanstalk.HandlerFunc(func(ctx context.Context, job *beanstalk.Job) error {
+					jobCh <- job
+
+					return testCase.handler(ctx, job)
+				}),
+				&beanstalk.WorkerOptions{
+					Dialer: func() (*beanstalk.Client, error) {
+						if atomic.AddInt32(&dials, 1) > 1 {
+							// the script was consumed completely
+							close(redialCh)
+
+							return nil, io.EOF
+						}
+
+						return beanstalk.NewClient(mock.NewConn(
+							append([]string{"watch test\r\n", "ignore default\r\n", "reserve-with-timeout 1\r\n"}, testCase.in...),
+							append([]string{"WATCHING 2\r\n", "WATCHING 1\r\n", "RESERVED 1 4\r\ntest\r\n"}, testCase.out...),
+						)), nil
+					},
+					Tubes:          []string{"test"},
+					ReserveTimeout: 1 * time.Second,
+					ReleaseDelay:   5 * time.Second,
+					ReconnectDelay: 1 * time.Minute,
+				},
+			)
+
+			require.NoError(t, worker.Start())
+			require.Equal(t, beanstalk.ErrAlreadyStartedWorker, worker.Start())
+
+			job := <-jobCh
+
+			require.Equal(t, 1, job.ID)
+			require.Equal(t, []byte("test"), job.Data)
+
+			<-redialCh
+
+			require.NoError(t, worker.Shutdown(context.Background()))
+			require.Equal(t, beanstalk.ErrStoppedWorker, worker.Shutdown(context.Background()))
+			require.Equal(t, beanstalk.ErrStoppedWorker, worker.Start())
+		})
+	}
+}
+
+func TestWorker_Shutdown(t *testing.T) {
+	t.Run("waits for in-flight job", func(t *testing.T) {
+		startedCh := make(chan struct{})
+		releaseCh := make(chan struct{})
+
+		worker := beanstalk.NewWorker(
+			beanstalk.HandlerFunc(func(ctx context.Context, job *beanstalk.Job) error {
+				close(startedCh)
+
+				<-releaseCh
+
+				return nil
+			}),
+			&beanstalk.WorkerOptions{
+				Dialer: func() (*beanstalk.Client, error) {
+					return beanstalk.NewClient(mock.NewConn(
+						[]string{"watch default\r\n", "reserve-with-timeout 5\r\n", "delete 1\r\n"},
+						[]string{"WATCHING 1\r\n", "RESERVED 1 4\r\ntest\r\n", "DELETED\r\n"},
+					)), nil
+				},
+				ReconnectDelay: 1 * time.Minute,
+			},
+		)
+
+		require.NoError(t, worker.Start())
+
+		<-startedCh
+
+		// timeout while job is in-flight
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		defer cancel()
+
+		require.Error(t, worker.Shutdown(ctx))
+
+		close(releaseCh)
+	})
+
+	t.Run("cancels handler context", func(t *testing.T) {
+		startedCh := make(chan struct{})
+		doneCh := make(chan error, 1)
+
+		worker := beanstalk.NewWorker(
+			beanstalk.HandlerFunc(func(ctx context.Context, job *beanstalk.Job) error {
+				close(startedCh)
+
+				<-ctx.Done()
+
+				doneCh <- ctx.Err()
+
+				return ctx.Err()
+			}),
+			&beanstalk.WorkerOptions{
+				Dialer: func() (*beanstalk.Client, error) {
+					return beanstalk.NewClient(mock.NewConn(
+						[]string{"watch default\r\n", "reserve-with-timeout 5\r\n"},
+						[]string{"WATCHING 1\r\n", "RESERVED 1 4\r\ntest\r\n"},
+					)), nil
+				},
+				ReconnectDelay: 1 * time.Minute,
+			},
+		)
+
+		require.NoError(t, worker.Start())
+
+		<-startedCh
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+
+		defer cancel()
+
+		require.Error(t, worker.Shutdown(ctx))
+		require.Equal(t, context.Canceled, <-doneCh)
+	})
+}